@@ -11,6 +11,8 @@ import (
 	"strings"
 	"time"
 
+	"pelagica-backend/persist"
+
 	"github.com/google/uuid"
 	"github.com/robfig/cron/v3"
 )
@@ -107,8 +109,7 @@ func WriteStatsConsent(consent bool) error {
 	if consent {
 		value = "true"
 	}
-	return os.WriteFile(file, []byte(value+"\n"), 0644)
-
+	return persist.WriteFile(file, []byte(value+"\n"), 0644)
 }
 
 func getInstanceId() (string, error) {
@@ -130,7 +131,7 @@ func getInstanceId() (string, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return "", fmt.Errorf("failed to create directories for instance ID file: %w", err)
 	}
-	if err := os.WriteFile(path, []byte(id), 0644); err != nil {
+	if err := persist.WriteFile(path, []byte(id), 0644); err != nil {
 		return "", fmt.Errorf("failed to write instance ID file: %w", err)
 	}
 
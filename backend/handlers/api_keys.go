@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"pelagica-backend/jellyfin"
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+var apiKeyStore *services.APIKeyStore
+
+func apiKeysPath() string {
+	return filepath.Join(filepath.Dir(configPath()), "api-keys.json")
+}
+
+func InitAPIKeyStore() {
+	store, err := services.NewAPIKeyStore(apiKeysPath())
+	if err != nil {
+		panic(err)
+	}
+	apiKeyStore = store
+}
+
+// apiKeyFromRequest returns the backend API key sent in X-API-Key or in
+// the Authorization header, if any.
+func apiKeyFromRequest(c fiber.Ctx) (string, bool) {
+	if key := strings.TrimSpace(c.Get(apiKeyHeader)); key != "" {
+		return key, true
+	}
+
+	token := jellyfin.TokenFromAuthorization(c.Get("Authorization"))
+	if services.IsAPIKey(token) {
+		return token, true
+	}
+
+	return "", false
+}
+
+// apiKeyPermissions expands the admin permission like callerPermissions
+// does for users.
+func apiKeyPermissions(key models.APIKey) []models.Permission {
+	for _, permission := range key.Permissions {
+		if permission == models.PermissionAdmin {
+			return models.Permissions
+		}
+	}
+	return key.Permissions
+}
+
+// requestActor identifies who authenticated the current request by ID (a
+// Jellyfin user ID or "api-key:<id>") and name. Both are empty when auth
+// is disabled.
+func requestActor(c fiber.Ctx) (string, string) {
+	if user, ok := c.Locals(authUserLocalsKey).(jellyfin.User); ok {
+		return user.Id, user.Name
+	}
+	if key, ok := c.Locals(authAPIKeyLocalsKey).(models.APIKey); ok {
+		return "api-key:" + key.ID, key.Name
+	}
+	return "", ""
+}
+
+func GetAPIKeys(c fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(apiKeyStore.List())
+}
+
+func CreateAPIKey(c fiber.Ctx) error {
+	var req models.CreateAPIKeyRequest
+
+	if err := c.Bind().Body(&req); err != nil {
+		log.Println("Error decoding API key request:", err)
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid API key request"})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if fieldErrors := req.Validate(); len(fieldErrors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid API key request", Fields: fieldErrors})
+	}
+
+	actorID, _ := requestActor(c)
+	created, err := apiKeyStore.Create(req, actorID)
+	if err != nil {
+		log.Println("Error creating API key:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to create API key"})
+	}
+
+	log.Printf("API key %s (%s) created", created.ID, created.Name)
+	recordAudit(c, models.AuditEntry{Action: models.AuditAPIKeyCreate, Target: created.ID, Details: fiber.Map{"name": created.Name, "permissions": created.Permissions}})
+
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+func RevokeAPIKey(c fiber.Ctx) error {
+	id := c.Params("id", "")
+
+	if err := apiKeyStore.Revoke(id); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "API key not found"})
+		}
+		log.Println("Error revoking API key:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to revoke API key"})
+	}
+
+	log.Printf("API key %s revoked", id)
+	recordAudit(c, models.AuditEntry{Action: models.AuditAPIKeyRevoke, Target: id})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	defaultAuditLogMaxBytes = 10 * 1024 * 1024
+	defaultAuditLogMaxFiles = 5
+	defaultAuditQueryLimit  = 100
+	maxAuditQueryLimit      = 1000
+)
+
+var auditLog *services.AuditLog
+
+func auditLogPath() string {
+	return filepath.Join(filepath.Dir(configPath()), "audit.jsonl")
+}
+
+func InitAuditLog() {
+	maxBytes := positiveFromEnv("AUDIT_LOG_MAX_BYTES", defaultAuditLogMaxBytes)
+
+	l, err := services.NewAuditLog(auditLogPath(), int64(maxBytes), fromEnv("AUDIT_LOG_MAX_FILES", defaultAuditLogMaxFiles))
+	if err != nil {
+		panic(err)
+	}
+	auditLog = l
+}
+
+// recordAudit appends entry to the audit log, attributed to the caller. A
+// failure is logged but never fails the change it describes.
+func recordAudit(c fiber.Ctx, entry models.AuditEntry) {
+	if auditLog == nil {
+		return
+	}
+
+	entry.ActorID, entry.ActorName = requestActor(c)
+
+	if err := auditLog.Append(entry); err != nil {
+		log.Println("Error writing audit log:", err)
+	}
+}
+
+// auditedWriteConfigFile writes the config like writeConfigFile and records
+// the change with a diff against the previous config.
+func auditedWriteConfigFile(c fiber.Ctx, action, target string, data []byte) error {
+	previous, err := readConfigFile()
+	if err != nil {
+		previous = []byte(`{}`)
+	}
+
+	if err := writeConfigFile(data); err != nil {
+		return err
+	}
+
+	changes, err := services.DiffJSON(previous, data)
+	if err != nil {
+		log.Println("Error diffing config for audit log:", err)
+	}
+
+	recordAudit(c, models.AuditEntry{Action: action, Target: target, Changes: changes})
+
+	return nil
+}
+
+func parseAuditTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// GetAuditLog lists audit entries, newest first. Supports ?actor= (user ID,
+// user name or api-key:<id>), ?action= (comma-separated), ?since= and
+// ?until= (RFC 3339) and ?limit=.
+func GetAuditLog(c fiber.Ctx) error {
+	filter := models.AuditFilter{
+		Actor: strings.TrimSpace(c.Query("actor")),
+		Limit: defaultAuditQueryLimit,
+	}
+
+	for _, action := range strings.Split(c.Query("action"), ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			filter.Actions = append(filter.Actions, action)
+		}
+	}
+
+	var err error
+	if filter.Since, err = parseAuditTime(strings.TrimSpace(c.Query("since"))); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "since must be an RFC 3339 timestamp"})
+	}
+	if filter.Until, err = parseAuditTime(strings.TrimSpace(c.Query("until"))); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "until must be an RFC 3339 timestamp"})
+	}
+
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "limit must be greater than 0"})
+		}
+		filter.Limit = min(limit, maxAuditQueryLimit)
+	}
+
+	entries, err := auditLog.Query(filter)
+	if err != nil {
+		log.Println("Error reading audit log:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read audit log"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(entries)
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"log"
+	"slices"
+	"strings"
+
 	"pelagica-backend/models"
 
 	"pelagica-backend/jellyfin"
@@ -9,18 +13,175 @@ import (
 	"github.com/gofiber/fiber/v3"
 )
 
+// authUserLocalsKey holds the authenticated jellyfin.User for the
+// rest of the request; authAPIKeyLocalsKey holds the models.APIKey when the
+// request was authenticated with a backend API key instead.
+const (
+	authUserLocalsKey   = "jellyfinUser"
+	authAPIKeyLocalsKey = "apiKey"
+)
+
+// authenticateCaller validates the token in the Authorization header
+// against the Jellyfin server resolved for the request. It also returns the
+// ID of that server, see serverIDForURL.
+func authenticateCaller(c fiber.Ctx) (jellyfin.User, string, error) {
+	jellyfinURL, err := resolveJellyfinURL(c)
+	if err != nil {
+		return jellyfin.User{}, "", err
+	}
+
+	header := strings.TrimSpace(c.Get("Authorization"))
+	if header == "" {
+		return jellyfin.User{}, "", errors.New("missing Authorization header")
+	}
+
+	token := jellyfin.TokenFromAuthorization(header)
+	if token == "" {
+		return jellyfin.User{}, "", errors.New("invalid Authorization header")
+	}
+
+	user, err := jellyfin.ResolveUser(jellyfinURL, token)
+	if err != nil {
+		return jellyfin.User{}, "", err
+	}
+
+	return user, serverIDForURL(jellyfinURL), nil
+}
+
+// callerPermissions returns everything the user of serverID may do there.
+// Jellyfin administrators and holders of the admin permission may do
+// anything.
+func callerPermissions(user jellyfin.User, serverID string) []models.Permission {
+	if user.Policy.IsAdministrator {
+		return models.Permissions
+	}
+
+	granted := roleStore.PermissionsFor(serverID, user.Id)
+	if slices.Contains(granted, models.PermissionAdmin) {
+		return models.Permissions
+	}
+
+	return granted
+}
+
+func permissionDenied(c fiber.Ctx, permission models.Permission) error {
+	if permission == models.PermissionAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(models.APIError{Error: "Admin access required"})
+	}
+	return c.Status(fiber.StatusForbidden).JSON(models.APIError{Error: "Permission required: " + string(permission)})
+}
+
+// RequirePermission returns a middleware that only lets through callers
+// holding permission, either as Jellyfin administrators, through a
+// delegated role or through a backend API key. Users only hold permissions
+// for the backend as a whole when they signed in to the home server.
+func RequirePermission(permission models.Permission) fiber.Handler {
+	return requirePermission(permission, false)
+}
+
+// RequireServerPermission is RequirePermission for the routes managing the
+// registered server named by the :id parameter. Users must have signed in
+// to that server and hold permission there.
+func RequireServerPermission(permission models.Permission) fiber.Handler {
+	return requirePermission(permission, true)
+}
+
+func requirePermission(permission models.Permission, perServer bool) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if secret, ok := apiKeyFromRequest(c); ok {
+			key, err := apiKeyStore.Authenticate(secret)
+			if err != nil {
+				log.Println("API key authentication error:", err)
+				return c.Status(fiber.StatusForbidden).JSON(models.APIError{Error: "Invalid API key"})
+			}
+
+			if !slices.Contains(apiKeyPermissions(key), permission) {
+				log.Printf("Authorization failed: API key %s lacks permission %q", key.ID, permission)
+				return permissionDenied(c, permission)
+			}
+
+			c.Locals(authAPIKeyLocalsKey, key)
+
+			return c.Next()
+		}
+
+		user, serverID, err := authenticateCaller(c)
+		if err != nil {
+			log.Println("Authentication error:", err)
+			return c.Status(fiber.StatusForbidden).JSON(models.APIError{Error: "Jellyfin Authentication failed"})
+		}
+
+		if perServer && serverID != c.Params("id") {
+			log.Printf("Authorization failed: %s is not signed in to server %q", user.Name, c.Params("id"))
+			return c.Status(fiber.StatusForbidden).JSON(models.APIError{Error: "Sign in to this server to manage it"})
+		}
+		if !perServer && serverID != homeServerID() {
+			log.Printf("Authorization failed: %s is not signed in to the home server", user.Name)
+			return permissionDenied(c, permission)
+		}
+
+		if !slices.Contains(callerPermissions(user, serverID), permission) {
+			log.Printf("Authorization failed: %s lacks permission %q", user.Name, permission)
+			return permissionDenied(c, permission)
+		}
+
+		c.Locals(authUserLocalsKey, user)
+
+		return c.Next()
+	}
+}
+
 func AuthMiddleware(c fiber.Ctx) error {
-	ok, err := jellyfin.AuthenticateByToken(c)
+	return RequirePermission(models.PermissionAdmin)(c)
+}
+
+// GetMyPermissions lists the permissions the calling user holds for the
+// backend as a whole so the frontend can decide which settings to show.
+func GetMyPermissions(c fiber.Ctx) error {
+	if secret, ok := apiKeyFromRequest(c); ok {
+		key, err := apiKeyStore.Authenticate(secret)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.APIError{Error: "Invalid API key"})
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"permissions": apiKeyPermissions(key)})
+	}
 
+	user, serverID, err := authenticateCaller(c)
 	if err != nil {
 		log.Println("Authentication error:", err)
-		return c.Status(fiber.StatusForbidden).JSON(models.APIError{Error: "Jellyfin Authentication failed"})
+		return c.Status(fiber.StatusUnauthorized).JSON(models.APIError{Error: "Jellyfin Authentication failed"})
 	}
 
-	if !ok {
-		log.Println("Authentication failed: admin access required")
-		return c.Status(fiber.StatusForbidden).JSON(models.APIError{Error: "Admin access required"})
+	permissions := []models.Permission{}
+	if serverID == homeServerID() {
+		permissions = callerPermissions(user, serverID)
 	}
 
-	return c.Next()
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"permissions": permissions})
+}
+
+// Logout drops the cached validation of the caller's token so it is checked
+// against Jellyfin again on the next request.
+func Logout(c fiber.Ctx) error {
+	header := strings.TrimSpace(c.Get("Authorization"))
+	if header != "" {
+		jellyfin.InvalidateToken(jellyfin.TokenFromAuthorization(header))
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// InitTokenCache applies the JELLYFIN_TOKEN_CACHE_* settings to the cache
+// of validated Jellyfin tokens.
+func InitTokenCache() {
+	defaults := jellyfin.DefaultTokenCacheConfig
+	jellyfin.ConfigureTokenCache(jellyfin.TokenCacheConfig{
+		TTL:         fromEnv("JELLYFIN_TOKEN_CACHE_TTL", defaults.TTL),
+		NegativeTTL: fromEnv("JELLYFIN_TOKEN_CACHE_NEGATIVE_TTL", defaults.NegativeTTL),
+		MaxEntries:  positiveFromEnv("JELLYFIN_TOKEN_CACHE_MAX_ENTRIES", defaults.MaxEntries),
+	})
+}
+
+func GetAuthCacheStats(c fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(jellyfin.TokenCacheStatistics())
 }
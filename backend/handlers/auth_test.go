@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"pelagica-backend/jellyfin/jellyfintest"
+	"pelagica-backend/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func newAuthTestApp() *fiber.App {
+	app := fiber.New()
+	ok := func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) }
+	app.Get("/admin", AuthMiddleware, ok)
+	app.Get("/themes", RequirePermission(models.PermissionManageThemes), ok)
+	return app
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	setupTestEnv(t)
+	jf := jellyfintest.NewServer(t)
+	app := newAuthTestApp()
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"jellyfin admin", jellyfintest.AdminToken, http.StatusNoContent},
+		{"regular user", jellyfintest.UserToken, http.StatusForbidden},
+		{"unknown token", "not-a-token", http.StatusForbidden},
+		{"missing token", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := doRequest(t, app, jf, "/admin", tt.token)
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestRequirePermissionDelegatedRole(t *testing.T) {
+	setupTestEnv(t)
+	jf := jellyfintest.NewServer(t)
+	app := newAuthTestApp()
+
+	if resp := doRequest(t, app, jf, "/themes", jellyfintest.UserToken); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status before delegation = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	err := roleStore.Write(models.RoleAssignments{
+		Roles: map[string]models.Role{
+			"designers": {
+				Permissions: []models.Permission{models.PermissionManageThemes},
+				Users:       []string{jellyfintest.UserUserID},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("writing roles: %v", err)
+	}
+
+	if resp := doRequest(t, app, jf, "/themes", jellyfintest.UserToken); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status with themes role = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if resp := doRequest(t, app, jf, "/admin", jellyfintest.UserToken); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("admin status with themes role = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestAuthMiddlewareJellyfinFailures(t *testing.T) {
+	setupTestEnv(t)
+	app := newAuthTestApp()
+
+	t.Run("unauthorized", func(t *testing.T) {
+		jf := jellyfintest.NewServer(t)
+		jf.Fail("/Users/Me", jellyfintest.Failure{Status: http.StatusUnauthorized})
+
+		resp := doRequest(t, app, jf, "/admin", jellyfintest.AdminToken)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+		}
+		if got := jf.Requests("/Users/Me"); got != 1 {
+			t.Errorf("Jellyfin called %d times, want 1", got)
+		}
+	})
+
+	t.Run("server error is retried", func(t *testing.T) {
+		jf := jellyfintest.NewServer(t)
+		jf.Fail("/Users/Me", jellyfintest.Failure{Status: http.StatusInternalServerError, Count: 1})
+
+		resp := doRequest(t, app, jf, "/admin", jellyfintest.AdminToken)
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+		}
+		if got := jf.Requests("/Users/Me"); got != 2 {
+			t.Errorf("Jellyfin called %d times, want 2", got)
+		}
+	})
+
+	t.Run("slow response", func(t *testing.T) {
+		jf := jellyfintest.NewServer(t)
+		jf.Fail("/Users/Me", jellyfintest.Failure{Delay: 100 * time.Millisecond})
+
+		resp := doRequest(t, app, jf, "/admin", jellyfintest.AdminToken)
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+		}
+	})
+}
+
+func TestRequirePermissionAPIKey(t *testing.T) {
+	setupTestEnv(t)
+	jf := jellyfintest.NewServer(t)
+	app := newAuthTestApp()
+
+	created, err := apiKeyStore.Create(models.CreateAPIKeyRequest{
+		Name:        "ci",
+		Permissions: []models.Permission{models.PermissionManageThemes},
+	}, "")
+	if err != nil {
+		t.Fatalf("creating API key: %v", err)
+	}
+
+	if resp := doRequest(t, app, jf, "/themes", created.Key); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("themes status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if resp := doRequest(t, app, jf, "/admin", created.Key); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("admin status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+	if got := jf.Requests("/Users/Me"); got != 0 {
+		t.Errorf("Jellyfin called %d times for API key requests, want 0", got)
+	}
+
+	keys := apiKeyStore.List()
+	if len(keys) != 1 || keys[0].LastUsedAt == nil {
+		t.Errorf("expected last-used timestamp to be recorded, got %+v", keys)
+	}
+
+	if err := apiKeyStore.Revoke(created.ID); err != nil {
+		t.Fatalf("revoking API key: %v", err)
+	}
+	if resp := doRequest(t, app, jf, "/themes", created.Key); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status after revoke = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
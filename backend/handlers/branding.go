@@ -4,17 +4,30 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"pelagica-backend/models"
+	"pelagica-backend/persist"
+	"pelagica-backend/services"
 
 	"github.com/gofiber/fiber/v3"
 )
 
-const maxBrandingLogoSizeBytes int64 = 10 * 1024 * 1024
+const (
+	maxBrandingAssetSizeBytes int64 = 10 * 1024 * 1024
+
+	// brandingAssetVersionLength is how many hex digits of an asset's hash
+	// go into the v parameter of its URL.
+	brandingAssetVersionLength = 16
+
+	// versionedAssetCacheControl is sent when a request names the current
+	// version of an asset, which can then never change under that URL.
+	versionedAssetCacheControl = "public, max-age=31536000, immutable"
+)
 
 func resolveBrandingLogoMode(mode string) (string, error) {
 	switch mode {
@@ -33,17 +46,157 @@ func brandingDir() string {
 	return dir
 }
 
+func brandingAssetPath(name string) string {
+	return filepath.Join(brandingDir(), "branding", name)
+}
+
 func brandingLogoPath(mode string) string {
-	return filepath.Join(brandingDir(), "branding", "logo-"+mode)
+	return brandingAssetPath("logo-" + mode)
+}
+
+// brandingAssetURL returns the versioned URL of the asset served at route,
+// given the hex encoded hash of its content. Replacing the asset changes
+// the URL, so clients can cache each version for good.
+func brandingAssetURL(route, hash string) string {
+	return route + "?v=" + hash[:brandingAssetVersionLength]
+}
+
+func brandingLogoRoute(mode string) string {
+	return "/api/branding/logo/" + mode
+}
+
+func brandingLogoURL(mode, hash string) string {
+	return brandingAssetURL(brandingLogoRoute(mode), hash)
+}
+
+// brandingLogoConfigKeys are the config members holding the logo URL of
+// each mode.
+var brandingLogoConfigKeys = map[string]string{"light": "logoLightUrl", "dark": "logoDarkUrl"}
+
+// versionLogoURLs points logo URLs saved before they were versioned at the
+// current version of their logo, so clients can cache every logo for good.
+// The stored config is left as it is.
+func versionLogoURLs(data []byte) []byte {
+	var cfg map[string]json.RawMessage
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return data
+	}
+
+	patch := map[string]string{}
+	for mode, key := range brandingLogoConfigKeys {
+		var url string
+		if json.Unmarshal(cfg[key], &url) != nil || url != brandingLogoRoute(mode) {
+			continue
+		}
+		if hash, _, err := services.FileHash(brandingLogoPath(mode)); err == nil {
+			patch[key] = brandingLogoURL(mode, hash)
+		}
+	}
+	if len(patch) == 0 {
+		return data
+	}
+
+	encoded, _ := json.Marshal(patch)
+	versioned, err := services.ApplyMergePatch(data, encoded)
+	if err != nil {
+		return data
+	}
+	return versioned
+}
+
+// setBrandingCacheControl lets clients cache the asset at path for good
+// when the request names its current version. Unversioned and outdated
+// URLs are revalidated so a replaced asset shows up right away.
+func setBrandingCacheControl(c fiber.Ctx, path string) {
+	c.Set(fiber.HeaderCacheControl, revalidateCacheControl)
+	if hash, _, err := services.FileHash(path); err == nil && c.Query("v") == hash[:brandingAssetVersionLength] {
+		c.Set(fiber.HeaderCacheControl, versionedAssetCacheControl)
+	}
+}
+
+// readBrandingUpload reads the image uploaded in the named form field. The
+// error is meant for the client.
+func readBrandingUpload(c fiber.Ctx, field string) ([]byte, error) {
+	fileHeader, err := c.FormFile(field)
+	if err != nil {
+		return nil, errors.New("Missing " + field + " file")
+	}
+
+	if fileHeader.Size > maxBrandingAssetSizeBytes {
+		return nil, errors.New(strings.ToUpper(field[:1]) + field[1:] + " file is too large (max 10MB)")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, errors.New("Failed to read uploaded file")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, errors.New("Failed to read uploaded file")
+	}
+
+	if err := checkBrandingImage(data); err != nil {
+		return nil, errors.New("Uploaded file must be an image")
+	}
+
+	return data, nil
+}
+
+// checkBrandingImage rejects branding files whose content is not an image.
+func checkBrandingImage(data []byte) error {
+	if !strings.HasPrefix(http.DetectContentType(data), "image/") {
+		return errors.New("file must be an image")
+	}
+	return nil
+}
+
+// saveBrandingAsset replaces the asset at path with data, dropping variants
+// rendered from the old one, and returns the hash of the new content.
+func saveBrandingAsset(path string, data []byte) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := persist.WriteFileWithBackup(path, data, 0644); err != nil {
+		return "", err
+	}
+	services.RemoveImageVariants(path)
+
+	return contentHash(data), nil
+}
+
+// removeBrandingAsset deletes the asset at path and its variants. A missing
+// asset is not an error.
+func removeBrandingAsset(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	services.RemoveImageVariants(path)
+
+	return nil
+}
+
+// updateBrandingConfig applies update to the config under configMu and
+// saves it, recording the change under action and target.
+func updateBrandingConfig(c fiber.Ctx, action, target string, update func(*models.AppConfig)) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	cfg, err := loadAppConfig()
+	if err != nil {
+		return err
+	}
+
+	update(&cfg)
+
+	return saveAppConfig(c, action, target, cfg)
 }
 
 func loadAppConfig() (models.AppConfig, error) {
 	var cfg models.AppConfig
-	data, err := os.ReadFile(configPath())
+	data, err := readConfigFile()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil
-		}
 		return cfg, err
 	}
 
@@ -58,12 +211,14 @@ func loadAppConfig() (models.AppConfig, error) {
 	return cfg, nil
 }
 
-func saveAppConfig(cfg models.AppConfig) error {
+// saveAppConfig writes cfg and records the change in the audit log under
+// action and target.
+func saveAppConfig(c fiber.Ctx, action, target string, cfg models.AppConfig) error {
 	data, err := json.MarshalIndent(cfg, "", "    ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(configPath(), data, 0644)
+	return auditedWriteConfigFile(c, action, target, data)
 }
 
 func GetBrandingLogo(c fiber.Ctx) error {
@@ -72,7 +227,8 @@ func GetBrandingLogo(c fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
 	}
 
-	logoData, err := os.ReadFile(brandingLogoPath(mode))
+	logoPath := brandingLogoPath(mode)
+	logoData, err := os.ReadFile(logoPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Logo not found"})
@@ -80,8 +236,8 @@ func GetBrandingLogo(c fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to load logo"})
 	}
 
-	c.Set("Content-Type", http.DetectContentType(logoData))
-	return c.Send(logoData)
+	setBrandingCacheControl(c, logoPath)
+	return sendImage(c, logoPath, http.DetectContentType(logoData))
 }
 
 func UploadBrandingLogo(c fiber.Ctx) error {
@@ -90,55 +246,32 @@ func UploadBrandingLogo(c fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
 	}
 
-	fileHeader, err := c.FormFile("logo")
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Missing logo file"})
-	}
-
-	if fileHeader.Size > maxBrandingLogoSizeBytes {
-		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Logo file is too large (max 10MB)"})
-	}
-
-	file, err := fileHeader.Open()
+	logoData, err := readBrandingUpload(c, "logo")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Failed to read uploaded file"})
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
 	}
-	defer file.Close()
 
-	logoData, err := io.ReadAll(file)
+	hash, err := saveBrandingAsset(brandingLogoPath(mode), logoData)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Failed to read uploaded file"})
-	}
-
-	if !strings.HasPrefix(http.DetectContentType(logoData), "image/") {
-		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Uploaded file must be an image"})
-	}
-
-	logoPath := brandingLogoPath(mode)
-	if err := os.MkdirAll(filepath.Dir(logoPath), 0755); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to create branding directory"})
-	}
-
-	if err := os.WriteFile(logoPath, logoData, 0644); err != nil {
+		log.Println("Error saving logo:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save logo"})
 	}
 
-	cfg, err := loadAppConfig()
+	logoURL := brandingLogoURL(mode, hash)
+	err = updateBrandingConfig(c, models.AuditLogoUpload, mode, func(cfg *models.AppConfig) {
+		if mode == "light" {
+			cfg.LogoLightURL = logoURL
+		} else {
+			cfg.LogoDarkURL = logoURL
+		}
+	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read config"})
-	}
-
-	logoURL := "/api/branding/logo/" + mode
-	if mode == "light" {
-		cfg.LogoLightURL = logoURL
-	} else {
-		cfg.LogoDarkURL = logoURL
-	}
-
-	if err := saveAppConfig(cfg); err != nil {
+		log.Println("Error updating config:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to update config"})
 	}
 
+	eventBus.Publish(models.EventBrandingLogoChanged, fiber.Map{"mode": mode, "url": logoURL})
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"url": logoURL})
 }
 
@@ -148,26 +281,24 @@ func ResetBrandingLogo(c fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
 	}
 
-	logoPath := brandingLogoPath(mode)
-	err = os.Remove(logoPath)
-	if err != nil && !os.IsNotExist(err) {
+	if err := removeBrandingAsset(brandingLogoPath(mode)); err != nil {
+		log.Println("Error removing logo:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to remove logo"})
 	}
 
-	cfg, err := loadAppConfig()
+	err = updateBrandingConfig(c, models.AuditLogoReset, mode, func(cfg *models.AppConfig) {
+		if mode == "light" {
+			cfg.LogoLightURL = ""
+		} else {
+			cfg.LogoDarkURL = ""
+		}
+	})
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read config"})
-	}
-
-	if mode == "light" {
-		cfg.LogoLightURL = ""
-	} else {
-		cfg.LogoDarkURL = ""
-	}
-
-	if err := saveAppConfig(cfg); err != nil {
+		log.Println("Error updating config:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to update config"})
 	}
 
+	eventBus.Publish(models.EventBrandingLogoChanged, fiber.Map{"mode": mode, "url": ""})
+
 	return c.SendStatus(fiber.StatusNoContent)
 }
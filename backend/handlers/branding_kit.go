@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	brandingIconName        = "icon"
+	brandingBackgroundName  = "login-background"
+	brandingIconRoute       = "/api/branding/icon"
+	brandingBackgroundRoute = "/api/branding/login-background"
+
+	// minBrandingIconSize keeps the largest generated icon from being
+	// scaled up.
+	minBrandingIconSize = 512
+
+	webManifestContentType = "application/manifest+json"
+	defaultAppName         = "Pelagica"
+	defaultAppDescription  = "Watch Movies and TV Shows from your Jellyfin server."
+	defaultAppColor        = "#181818"
+)
+
+// brandingIconSizes are the PNG icons generated from the uploaded icon:
+// favicons, the apple-touch icon and the web app manifest icons.
+var brandingIconSizes = []int{16, 32, 96, 180, 192, 512}
+
+// webManifestIconSizes are the brandingIconSizes listed in the manifest.
+var webManifestIconSizes = []int{192, 512}
+
+// defaultWebManifestIcons are served by the frontend and used until an icon
+// is uploaded.
+var defaultWebManifestIcons = []models.WebManifestIcon{
+	{Src: "/favicons/web-app-manifest-192x192.png", Sizes: "192x192", Type: "image/png", Purpose: "maskable"},
+	{Src: "/favicons/web-app-manifest-512x512.png", Sizes: "512x512", Type: "image/png", Purpose: "maskable"},
+}
+
+func brandingIconSizeList() string {
+	sizes := make([]string, len(brandingIconSizes))
+	for i, size := range brandingIconSizes {
+		sizes[i] = strconv.Itoa(size)
+	}
+	return strings.Join(sizes, ", ")
+}
+
+// brandingIconSizeURL returns the versioned URL of the generated icon of
+// size, given the hash of the uploaded icon.
+func brandingIconSizeURL(size int, hash string) string {
+	return brandingAssetURL(brandingIconRoute+"/"+strconv.Itoa(size), hash)
+}
+
+// GetBrandingIcon serves the uploaded icon or, with a size, the PNG icon
+// generated from it.
+func GetBrandingIcon(c fiber.Ctx) error {
+	iconPath := brandingAssetPath(brandingIconName)
+	iconData, err := os.ReadFile(iconPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Icon not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to load icon"})
+	}
+
+	setBrandingCacheControl(c, iconPath)
+
+	rawSize := c.Params("size")
+	if rawSize == "" {
+		return sendImage(c, iconPath, http.DetectContentType(iconData))
+	}
+
+	size, err := strconv.Atoi(rawSize)
+	if err != nil || !slices.Contains(brandingIconSizes, size) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "size must be one of " + brandingIconSizeList()})
+	}
+
+	path, contentType, err := services.RenderImageVariant(iconPath, services.ImageVariant{Width: size, Height: size, Format: services.ImageFormatPNG})
+	if err != nil {
+		log.Printf("Error rendering %dpx icon: %v", size, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to render icon"})
+	}
+
+	return sendImageFile(c, path, contentType)
+}
+
+// checkBrandingIcon rejects icons the generated icons cannot be rendered
+// from without distortion or upscaling.
+func checkBrandingIcon(data []byte) error {
+	width, height, err := services.ImageSize(data)
+	if err != nil {
+		return errors.New("Icon must be a PNG, JPEG, WebP or GIF image")
+	}
+	if width != height || width < minBrandingIconSize {
+		return errors.New("Icon must be square and at least " + strconv.Itoa(minBrandingIconSize) + "x" + strconv.Itoa(minBrandingIconSize) + " pixels")
+	}
+	return nil
+}
+
+// UploadBrandingIcon stores a square image as the app icon and generates
+// the favicons, apple-touch icon and web app manifest icons from it.
+func UploadBrandingIcon(c fiber.Ctx) error {
+	iconData, err := readBrandingUpload(c, "icon")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
+	}
+
+	if err := checkBrandingIcon(iconData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
+	}
+
+	iconPath := brandingAssetPath(brandingIconName)
+	hash, err := saveBrandingAsset(iconPath, iconData)
+	if err != nil {
+		log.Println("Error saving icon:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save icon"})
+	}
+
+	for _, size := range brandingIconSizes {
+		variant := services.ImageVariant{Width: size, Height: size, Format: services.ImageFormatPNG}
+		if _, _, err := services.RenderImageVariant(iconPath, variant); err != nil {
+			log.Printf("Error rendering %dpx icon: %v", size, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to generate icons"})
+		}
+	}
+
+	iconURL := brandingAssetURL(brandingIconRoute, hash)
+	err = updateBrandingConfig(c, models.AuditIconUpload, brandingIconName, func(cfg *models.AppConfig) {
+		cfg.IconURL = iconURL
+	})
+	if err != nil {
+		log.Println("Error updating config:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to update config"})
+	}
+
+	eventBus.Publish(models.EventBrandingIconChanged, fiber.Map{"url": iconURL})
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"url": iconURL})
+}
+
+func ResetBrandingIcon(c fiber.Ctx) error {
+	if err := removeBrandingAsset(brandingAssetPath(brandingIconName)); err != nil {
+		log.Println("Error removing icon:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to remove icon"})
+	}
+
+	err := updateBrandingConfig(c, models.AuditIconReset, brandingIconName, func(cfg *models.AppConfig) {
+		cfg.IconURL = ""
+	})
+	if err != nil {
+		log.Println("Error updating config:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to update config"})
+	}
+
+	eventBus.Publish(models.EventBrandingIconChanged, fiber.Map{"url": ""})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func GetLoginBackground(c fiber.Ctx) error {
+	backgroundPath := brandingAssetPath(brandingBackgroundName)
+	backgroundData, err := os.ReadFile(backgroundPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Login background not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to load login background"})
+	}
+
+	setBrandingCacheControl(c, backgroundPath)
+	return sendImage(c, backgroundPath, http.DetectContentType(backgroundData))
+}
+
+func UploadLoginBackground(c fiber.Ctx) error {
+	backgroundData, err := readBrandingUpload(c, "background")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
+	}
+
+	hash, err := saveBrandingAsset(brandingAssetPath(brandingBackgroundName), backgroundData)
+	if err != nil {
+		log.Println("Error saving login background:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save login background"})
+	}
+
+	backgroundURL := brandingAssetURL(brandingBackgroundRoute, hash)
+	err = updateBrandingConfig(c, models.AuditBackgroundUpload, brandingBackgroundName, func(cfg *models.AppConfig) {
+		cfg.LoginBackgroundURL = backgroundURL
+	})
+	if err != nil {
+		log.Println("Error updating config:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to update config"})
+	}
+
+	eventBus.Publish(models.EventBackgroundChanged, fiber.Map{"url": backgroundURL})
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"url": backgroundURL})
+}
+
+func ResetLoginBackground(c fiber.Ctx) error {
+	if err := removeBrandingAsset(brandingAssetPath(brandingBackgroundName)); err != nil {
+		log.Println("Error removing login background:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to remove login background"})
+	}
+
+	err := updateBrandingConfig(c, models.AuditBackgroundReset, brandingBackgroundName, func(cfg *models.AppConfig) {
+		cfg.LoginBackgroundURL = ""
+	})
+	if err != nil {
+		log.Println("Error updating config:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to update config"})
+	}
+
+	eventBus.Publish(models.EventBackgroundChanged, fiber.Map{"url": ""})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// webManifestColor returns the background color of the server theme as a
+// hex color, preferring its dark variant like the app does by default.
+func webManifestColor(themeID string) string {
+	if themeID == "" {
+		return defaultAppColor
+	}
+
+	theme, err := themeStore.Get(themeID)
+	if err != nil {
+		return defaultAppColor
+	}
+
+	colors := theme.Colors.Light
+	if slices.Contains(theme.Modes, "dark") {
+		colors = theme.Colors.Dark
+	}
+	if color, ok := services.CSSColorToHex(colors["background"]); ok {
+		return color
+	}
+	return defaultAppColor
+}
+
+// GetWebManifest builds the web app manifest from the server name, the
+// server theme and the uploaded icon.
+func GetWebManifest(c fiber.Ctx) error {
+	// The name and colours come from the server the request picked.
+	c.Set(fiber.HeaderVary, serverHeader)
+
+	data, err := readConfigFile()
+	if err != nil {
+		log.Println("Error reading config file:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read config file"})
+	}
+
+	var cfg models.AppConfig
+	if data = applyServerConfig(c, data); len(data) > 0 {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			log.Println("Error parsing config file:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to parse config file"})
+		}
+	}
+
+	name := strings.TrimSpace(cfg.ServerName)
+	if name == "" {
+		name = defaultAppName
+	}
+	color := webManifestColor(cfg.ServerThemeId)
+
+	manifest := models.WebManifest{
+		Name:            name,
+		ShortName:       name,
+		Description:     defaultAppDescription,
+		Display:         "standalone",
+		Categories:      []string{"entertainment", "video", "movies", "tv"},
+		StartURL:        "/",
+		Icons:           defaultWebManifestIcons,
+		ThemeColor:      color,
+		BackgroundColor: color,
+	}
+
+	if hash, _, err := services.FileHash(brandingAssetPath(brandingIconName)); err == nil {
+		manifest.Icons = make([]models.WebManifestIcon, 0, len(webManifestIconSizes))
+		for _, size := range webManifestIconSizes {
+			manifest.Icons = append(manifest.Icons, models.WebManifestIcon{
+				Src:   brandingIconSizeURL(size, hash),
+				Sizes: strconv.Itoa(size) + "x" + strconv.Itoa(size),
+				Type:  "image/png",
+			})
+		}
+	}
+
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to build manifest"})
+	}
+
+	c.Set(fiber.HeaderCacheControl, revalidateCacheControl)
+	if notModified(c, contentETag(body), time.Time{}) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	c.Set(fiber.HeaderContentType, webManifestContentType)
+	return c.Send(body)
+}
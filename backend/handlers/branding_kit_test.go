@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"pelagica-backend/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func uploadBrandingIcon(t *testing.T, app *fiber.App, width, height int) *http.Response {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("icon", "icon.png")
+	if err != nil {
+		t.Fatalf("creating form: %v", err)
+	}
+	png.Encode(part, image.NewNRGBA(image.Rect(0, 0, width, height)))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/branding/icon", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := app.Test(req, fiber.TestConfig{Timeout: 0})
+	if err != nil {
+		t.Fatalf("POST icon: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestBrandingIconAndManifest(t *testing.T) {
+	setupTestEnv(t)
+	t.Setenv("THEMES_DIR", t.TempDir())
+	InitThemeStore()
+
+	theme := models.Theme{
+		Name:   "Harbor",
+		Modes:  []string{"light", "dark"},
+		Colors: models.Colors{Light: map[string]string{"background": "#ffffff"}, Dark: map[string]string{"background": "oklch(0.145 0 0)"}},
+	}
+	if _, err := themeStore.Write("harbor", theme); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath(), []byte(`{"serverName":"Harbor TV","serverThemeId":"harbor"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/branding/icon/:size?", GetBrandingIcon)
+	app.Post("/branding/icon", UploadBrandingIcon)
+	app.Get("/branding/manifest.webmanifest", GetWebManifest)
+
+	get := func(target string) *http.Response {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		if err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	var manifest models.WebManifest
+	manifestResp := get("/branding/manifest.webmanifest")
+	if got := manifestResp.Header.Get("Vary"); got != serverHeader {
+		t.Errorf("manifest Vary = %q, want %q", got, serverHeader)
+	}
+	decodeBody(t, manifestResp, &manifest)
+	if manifest.Name != "Harbor TV" || manifest.ThemeColor != "#0a0a0a" || manifest.BackgroundColor != "#0a0a0a" {
+		t.Errorf("manifest name = %q, colors = %q/%q", manifest.Name, manifest.ThemeColor, manifest.BackgroundColor)
+	}
+	if len(manifest.Icons) != 2 || !strings.HasPrefix(manifest.Icons[0].Src, "/favicons/") {
+		t.Errorf("manifest icons before upload = %+v, want the defaults", manifest.Icons)
+	}
+
+	if resp := uploadBrandingIcon(t, app, 600, 400); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("non-square icon status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	if resp := uploadBrandingIcon(t, app, 256, 256); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("small icon status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	var uploaded struct {
+		URL string `json:"url"`
+	}
+	resp := uploadBrandingIcon(t, app, 600, 600)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("icon upload status = %d", resp.StatusCode)
+	}
+	decodeBody(t, resp, &uploaded)
+
+	cfg, err := loadAppConfig()
+	if err != nil || cfg.IconURL != uploaded.URL || !strings.HasPrefix(uploaded.URL, brandingIconRoute+"?v=") {
+		t.Errorf("config icon URL = %q (%v), upload returned %q", cfg.IconURL, err, uploaded.URL)
+	}
+
+	for _, size := range brandingIconSizes {
+		resp := get("/branding/icon/" + strconv.Itoa(size))
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" {
+			t.Fatalf("%dpx icon: status = %d, content type = %s", size, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		config, err := png.DecodeConfig(resp.Body)
+		if err != nil || config.Width != size || config.Height != size {
+			t.Errorf("%dpx icon is %dx%d (%v)", size, config.Width, config.Height, err)
+		}
+	}
+	if resp := get("/branding/icon/100"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unsupported size status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	decodeBody(t, get("/branding/manifest.webmanifest"), &manifest)
+	if len(manifest.Icons) != 2 || manifest.Icons[1].Sizes != "512x512" || !strings.HasPrefix(manifest.Icons[1].Src, brandingIconRoute+"/512?v=") {
+		t.Errorf("manifest icons after upload = %+v", manifest.Icons)
+	}
+}
+
+func TestParseImportBundleChecksBrandingFiles(t *testing.T) {
+	encodePNG := func(width, height int) []byte {
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height)))
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		wantErr bool
+	}{
+		{"icon", "branding/icon", encodePNG(512, 512), false},
+		{"small icon", "branding/icon", encodePNG(64, 64), true},
+		{"wide icon", "branding/icon", encodePNG(1024, 512), true},
+		{"background", "branding/login-background", encodePNG(64, 32), false},
+		{"background not an image", "branding/login-background", []byte("<html></html>"), true},
+		{"logo", "branding/logo-light", encodePNG(200, 50), false},
+		{"logo not an image", "branding/logo-light", []byte("#!/bin/sh"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			archive := zip.NewWriter(&buf)
+			manifest, _ := archive.Create(bundleManifestName)
+			json.NewEncoder(manifest).Encode(models.BundleManifest{Format: models.BundleFormat, FormatVersion: models.BundleFormatVersion})
+			entry, _ := archive.Create(tt.file)
+			entry.Write(tt.content)
+			archive.Close()
+
+			_, err := parseImportBundle(buf.Bytes())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseImportBundle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,518 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"pelagica-backend/collector"
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	maxBundleSizeBytes      int64 = 48 * 1024 * 1024
+	maxBundleEntrySizeBytes int64 = maxBrandingAssetSizeBytes
+	bundleManifestName            = "manifest.json"
+	bundleConfigName              = "config.json"
+	bundleThemesDir               = "themes/"
+	bundleBrandingDir             = "branding/"
+)
+
+var bundleThemeIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+var brandingLogoModes = []string{"light", "dark"}
+
+// brandingBundleAssets are the branding files besides the logos that
+// bundles carry, with the check their upload applies and the event
+// announcing a change to each.
+var brandingBundleAssets = []struct {
+	name  string
+	route string
+	event string
+	check func([]byte) error
+}{
+	{brandingIconName, brandingIconRoute, models.EventBrandingIconChanged, checkBrandingIcon},
+	{brandingBackgroundName, brandingBackgroundRoute, models.EventBackgroundChanged, checkBrandingImage},
+}
+
+func appVersion() string {
+	return strings.TrimSpace(os.Getenv("APP_VERSION"))
+}
+
+// majorVersion returns the major component of a semantic version, or -1 if
+// the version is unknown.
+func majorVersion(version string) int {
+	major, _, _ := strings.Cut(strings.TrimPrefix(version, "v"), ".")
+	value, err := strconv.Atoi(major)
+	if err != nil || version == "0.0.0" {
+		return -1
+	}
+	return value
+}
+
+// checkBundleCompatibility rejects bundles written in a newer format or by a
+// backend with a different major version.
+func checkBundleCompatibility(manifest models.BundleManifest) error {
+	if manifest.Format != models.BundleFormat {
+		return errors.New("not a Pelagica export bundle")
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > models.BundleFormatVersion {
+		return fmt.Errorf("unsupported bundle format version %d", manifest.FormatVersion)
+	}
+
+	bundleMajor := majorVersion(manifest.AppVersion)
+	currentMajor := majorVersion(appVersion())
+	if bundleMajor >= 0 && currentMajor >= 0 && bundleMajor != currentMajor {
+		return fmt.Errorf("bundle was exported by Pelagica %s, which is incompatible with this backend (%s)", manifest.AppVersion, appVersion())
+	}
+
+	return nil
+}
+
+func exportStatsConsent() *bool {
+	var consent bool
+	switch collector.HasStatsConsent() {
+	case collector.ConsentGiven:
+		consent = true
+	case collector.ConsentDenied:
+		consent = false
+	default:
+		return nil
+	}
+	return &consent
+}
+
+func ExportBundle(c fiber.Ctx) error {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	manifest := models.BundleManifest{
+		Format:        models.BundleFormat,
+		FormatVersion: models.BundleFormatVersion,
+		AppVersion:    appVersion(),
+		CreatedAt:     time.Now().UTC(),
+		Themes:        []string{},
+		Logos:         []string{},
+		Assets:        []string{},
+		StatsConsent:  exportStatsConsent(),
+	}
+
+	addFile := func(name string, data []byte) error {
+		w, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: manifest.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	configData, err := readConfigFile()
+	if err != nil {
+		log.Println("Error reading config file:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read config file"})
+	}
+	if err := addFile(bundleConfigName, configData); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to build export"})
+	}
+
+	for _, summary := range themeStore.GetAll() {
+		theme, err := themeStore.Get(summary.ID)
+		if err != nil {
+			continue
+		}
+		data, err := json.MarshalIndent(theme, "", "  ")
+		if err != nil {
+			continue
+		}
+		if err := addFile(bundleThemesDir+summary.ID+".json", data); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to build export"})
+		}
+		manifest.Themes = append(manifest.Themes, summary.ID)
+	}
+
+	for _, mode := range brandingLogoModes {
+		data, err := os.ReadFile(brandingLogoPath(mode))
+		if err != nil {
+			continue
+		}
+		if err := addFile(bundleBrandingDir+"logo-"+mode, data); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to build export"})
+		}
+		manifest.Logos = append(manifest.Logos, mode)
+	}
+
+	for _, asset := range brandingBundleAssets {
+		data, err := os.ReadFile(brandingAssetPath(asset.name))
+		if err != nil {
+			continue
+		}
+		if err := addFile(bundleBrandingDir+asset.name, data); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to build export"})
+		}
+		manifest.Assets = append(manifest.Assets, asset.name)
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to build export"})
+	}
+	if err := addFile(bundleManifestName, manifestData); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to build export"})
+	}
+
+	if err := archive.Close(); err != nil {
+		log.Println("Error finalizing export:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to build export"})
+	}
+
+	filename := "pelagica-export-" + manifest.CreatedAt.Format("20060102-150405") + ".zip"
+	c.Set("Content-Type", "application/zip")
+	c.Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	return c.Send(buf.Bytes())
+}
+
+type importBundle struct {
+	manifest models.BundleManifest
+	config   []byte
+	themes   map[string]models.Theme
+	logos    map[string][]byte
+	assets   map[string][]byte
+}
+
+func readBundleEntry(file *zip.File) ([]byte, error) {
+	if int64(file.UncompressedSize64) > maxBundleEntrySizeBytes {
+		return nil, fmt.Errorf("%s is too large", file.Name)
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// The size in the header is not trusted; reading one byte past the limit
+	// tells an entry that is too large from one that just fits.
+	data, err := io.ReadAll(io.LimitReader(rc, maxBundleEntrySizeBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBundleEntrySizeBytes {
+		return nil, fmt.Errorf("%s is too large", file.Name)
+	}
+
+	return data, nil
+}
+
+func parseImportBundle(data []byte) (*importBundle, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("bundle is not a valid zip archive")
+	}
+
+	bundle := &importBundle{
+		themes: map[string]models.Theme{},
+		logos:  map[string][]byte{},
+		assets: map[string][]byte{},
+	}
+	hasManifest := false
+
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		name := path.Clean(file.Name)
+		content, err := readBundleEntry(file)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case name == bundleManifestName:
+			if err := json.Unmarshal(content, &bundle.manifest); err != nil {
+				return nil, errors.New("invalid manifest.json")
+			}
+			hasManifest = true
+		case name == bundleConfigName:
+			bundle.config = content
+		case strings.HasPrefix(name, bundleThemesDir) && strings.HasSuffix(name, ".json"):
+			id := strings.TrimSuffix(strings.TrimPrefix(name, bundleThemesDir), ".json")
+			if !bundleThemeIDPattern.MatchString(id) {
+				return nil, fmt.Errorf("invalid theme id %q", id)
+			}
+			var theme models.Theme
+			if err := json.Unmarshal(content, &theme); err != nil {
+				return nil, fmt.Errorf("invalid theme %s: %w", id, err)
+			}
+			if err := theme.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid theme %s: %w", id, err)
+			}
+			bundle.themes[id] = theme
+		case strings.HasPrefix(name, bundleBrandingDir+"logo-"):
+			mode, err := resolveBrandingLogoMode(strings.TrimPrefix(name, bundleBrandingDir+"logo-"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid branding file %s", name)
+			}
+			if err := checkBrandingImage(content); err != nil {
+				return nil, fmt.Errorf("invalid branding file %s: %w", name, err)
+			}
+			bundle.logos[mode] = content
+		case strings.HasPrefix(name, bundleBrandingDir):
+			for _, asset := range brandingBundleAssets {
+				if name != bundleBrandingDir+asset.name {
+					continue
+				}
+				if err := asset.check(content); err != nil {
+					return nil, fmt.Errorf("invalid branding file %s: %w", name, err)
+				}
+				bundle.assets[asset.name] = content
+			}
+		}
+	}
+
+	if !hasManifest {
+		return nil, errors.New("bundle is missing manifest.json")
+	}
+	if err := checkBundleCompatibility(bundle.manifest); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+// ImportBundle restores an export bundle. With mode=replace, themes, logos
+// and other branding assets missing from the bundle are removed and the
+// config is replaced; the default mode=merge only adds and overwrites.
+// dry_run=true reports the changes without applying them.
+func ImportBundle(c fiber.Ctx) error {
+	mode := c.Query("mode", models.ImportModeMerge)
+	if mode != models.ImportModeMerge && mode != models.ImportModeReplace {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "mode must be either merge or replace"})
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	fileHeader, err := c.FormFile("bundle")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Missing bundle file"})
+	}
+	if fileHeader.Size > maxBundleSizeBytes {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Bundle file is too large"})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Failed to read uploaded file"})
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Failed to read uploaded file"})
+	}
+
+	bundle, err := parseImportBundle(data)
+	if err != nil {
+		log.Println("Error parsing import bundle:", err)
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid bundle: " + err.Error()})
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	current, err := readConfigFile()
+	if err != nil {
+		log.Println("Error reading config file:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read config file"})
+	}
+
+	var newConfig []byte
+	if bundle.config != nil {
+		imported, _, err := services.MigrateConfig(bundle.config)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid bundle config: " + err.Error()})
+		}
+		if mode == models.ImportModeMerge {
+			imported, err = services.ApplyMergePatch(current, imported)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid bundle config"})
+			}
+		}
+
+		var cfg models.AppConfig
+		if err := json.Unmarshal(imported, &cfg); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid bundle config"})
+		}
+		if fieldErrors := cfg.Validate(); len(fieldErrors) > 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid bundle config", Fields: fieldErrors})
+		}
+
+		newConfig, err = encodeConfig(cfg)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to encode config"})
+		}
+	}
+
+	report := models.ImportReport{
+		DryRun:        dryRun,
+		Mode:          mode,
+		ConfigChanges: []models.ConfigChange{},
+		ThemesCreated: []string{},
+		ThemesUpdated: []string{},
+		ThemesDeleted: []string{},
+		LogosUpdated:  []string{},
+		LogosRemoved:  []string{},
+		AssetsUpdated: []string{},
+		AssetsRemoved: []string{},
+		StatsConsent:  bundle.manifest.StatsConsent,
+	}
+
+	if newConfig != nil {
+		report.ConfigChanges, err = services.DiffJSON(current, newConfig)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to diff config"})
+		}
+	}
+
+	for id := range bundle.themes {
+		if _, err := themeStore.Get(id); err == nil {
+			report.ThemesUpdated = append(report.ThemesUpdated, id)
+		} else {
+			report.ThemesCreated = append(report.ThemesCreated, id)
+		}
+	}
+	if mode == models.ImportModeReplace {
+		for _, summary := range themeStore.GetAll() {
+			if _, ok := bundle.themes[summary.ID]; !ok {
+				report.ThemesDeleted = append(report.ThemesDeleted, summary.ID)
+			}
+		}
+	}
+
+	for _, logoMode := range brandingLogoModes {
+		if _, ok := bundle.logos[logoMode]; ok {
+			report.LogosUpdated = append(report.LogosUpdated, logoMode)
+		} else if mode == models.ImportModeReplace {
+			if _, err := os.Stat(brandingLogoPath(logoMode)); err == nil {
+				report.LogosRemoved = append(report.LogosRemoved, logoMode)
+			}
+		}
+	}
+
+	for _, asset := range brandingBundleAssets {
+		if _, ok := bundle.assets[asset.name]; ok {
+			report.AssetsUpdated = append(report.AssetsUpdated, asset.name)
+		} else if mode == models.ImportModeReplace {
+			if _, err := os.Stat(brandingAssetPath(asset.name)); err == nil {
+				report.AssetsRemoved = append(report.AssetsRemoved, asset.name)
+			}
+		}
+	}
+
+	if dryRun {
+		return c.Status(fiber.StatusOK).JSON(report)
+	}
+
+	for _, logoMode := range report.LogosUpdated {
+		hash, err := saveBrandingAsset(brandingLogoPath(logoMode), bundle.logos[logoMode])
+		if err != nil {
+			log.Println("Error importing logo:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to import logo"})
+		}
+		eventBus.Publish(models.EventBrandingLogoChanged, fiber.Map{"mode": logoMode, "url": brandingLogoURL(logoMode, hash)})
+	}
+	for _, logoMode := range report.LogosRemoved {
+		if err := removeBrandingAsset(brandingLogoPath(logoMode)); err != nil {
+			log.Println("Error removing logo:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to remove logo"})
+		}
+		eventBus.Publish(models.EventBrandingLogoChanged, fiber.Map{"mode": logoMode, "url": ""})
+	}
+
+	for _, asset := range brandingBundleAssets {
+		if data, ok := bundle.assets[asset.name]; ok {
+			hash, err := saveBrandingAsset(brandingAssetPath(asset.name), data)
+			if err != nil {
+				log.Println("Error importing branding asset:", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to import " + asset.name})
+			}
+			eventBus.Publish(asset.event, fiber.Map{"url": brandingAssetURL(asset.route, hash)})
+		} else if slices.Contains(report.AssetsRemoved, asset.name) {
+			if err := removeBrandingAsset(brandingAssetPath(asset.name)); err != nil {
+				log.Println("Error removing branding asset:", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to remove " + asset.name})
+			}
+			eventBus.Publish(asset.event, fiber.Map{"url": ""})
+		}
+	}
+
+	for id, theme := range bundle.themes {
+		if _, err := themeStore.Write(id, theme); err != nil {
+			log.Println("Error importing theme:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to import theme " + id})
+		}
+	}
+	for _, id := range report.ThemesCreated {
+		eventBus.Publish(models.EventThemeCreated, fiber.Map{"id": id})
+	}
+	for _, id := range report.ThemesUpdated {
+		eventBus.Publish(models.EventThemeUpdated, fiber.Map{"id": id})
+	}
+	for _, id := range report.ThemesDeleted {
+		if err := themeStore.Delete(id); err != nil {
+			log.Println("Error deleting theme:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to delete theme " + id})
+		}
+		eventBus.Publish(models.EventThemeDeleted, fiber.Map{"id": id})
+	}
+
+	if newConfig != nil && len(report.ConfigChanges) > 0 {
+		if err := writeConfigFile(newConfig); err != nil {
+			log.Println("Error writing config file:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save config"})
+		}
+	}
+
+	if bundle.manifest.StatsConsent != nil {
+		if err := collector.WriteStatsConsent(*bundle.manifest.StatsConsent); err != nil {
+			log.Println("Skipping stats consent import:", err)
+		}
+	}
+
+	log.Printf("Bundle imported (%s): %d config change(s), %d theme(s), %d logo(s)",
+		mode, len(report.ConfigChanges), len(bundle.themes), len(report.LogosUpdated))
+
+	recordAudit(c, models.AuditEntry{
+		Action:  models.AuditBundleImport,
+		Target:  mode,
+		Changes: report.ConfigChanges,
+		Details: fiber.Map{
+			"themesCreated": report.ThemesCreated,
+			"themesUpdated": report.ThemesUpdated,
+			"themesDeleted": report.ThemesDeleted,
+			"logosUpdated":  report.LogosUpdated,
+			"logosRemoved":  report.LogosRemoved,
+			"assetsUpdated": report.AssetsUpdated,
+			"assetsRemoved": report.AssetsRemoved,
+		},
+	})
+
+	return c.Status(fiber.StatusOK).JSON(report)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// revalidateCacheControl lets clients keep a response but makes them check
+// it is still current, which the validators below turn into a cheap 304.
+const revalidateCacheControl = "no-cache"
+
+// contentHash returns the hex encoded SHA-256 of data, matching
+// services.FileHash for files.
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// contentETag returns a strong entity tag for the given content.
+func contentETag(data []byte) string {
+	return `"` + contentHash(data)[:32] + `"`
+}
+
+// fileETag returns the entity tag and modification time of the file at path.
+// The tag equals contentETag of the file's content.
+func fileETag(path string) (string, time.Time, error) {
+	hash, modTime, err := services.FileHash(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return `"` + hash[:32] + `"`, modTime, nil
+}
+
+// etagMatches compares etag against an If-None-Match header using the weak
+// comparison RFC 9110 prescribes for it.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag and, when known, Last-Modified validators and
+// reports whether the request's If-None-Match or, failing that,
+// If-Modified-Since header shows the client already has this version.
+func notModified(c fiber.Ctx, etag string, lastModified time.Time) bool {
+	c.Set(fiber.HeaderETag, etag)
+	if !lastModified.IsZero() {
+		c.Set(fiber.HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+		return false
+	}
+
+	if header := c.Get(fiber.HeaderIfNoneMatch); header != "" {
+		return etagMatches(header, etag)
+	}
+
+	if header := c.Get(fiber.HeaderIfModifiedSince); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// sendConditionalJSON sends data as JSON with validators, answering 304 when
+// the client's copy is still current.
+func sendConditionalJSON(c fiber.Ctx, data []byte, lastModified time.Time) error {
+	c.Set(fiber.HeaderCacheControl, revalidateCacheControl)
+	if notModified(c, contentETag(data), lastModified) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.Status(fiber.StatusOK).
+		Type("json").
+		Send(data)
+}
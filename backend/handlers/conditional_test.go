@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	setupTestEnv(t)
+
+	logoPath := brandingLogoPath("light")
+	os.MkdirAll(filepath.Dir(logoPath), 0755)
+	logo := []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"/>")
+	if err := os.WriteFile(logoPath, logo, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath(), []byte(`{"serverName":"Test"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/branding/logo/:mode", GetBrandingLogo)
+	app.Get("/config", GetConfig)
+
+	get := func(target string, header map[string]string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	logoResp := get("/branding/logo/light", nil)
+	etag := logoResp.Header.Get("ETag")
+	if logoResp.StatusCode != http.StatusOK || etag == "" || logoResp.Header.Get("Last-Modified") == "" {
+		t.Fatalf("logo: status = %d, ETag = %q, Last-Modified = %q", logoResp.StatusCode, etag, logoResp.Header.Get("Last-Modified"))
+	}
+	if got := logoResp.Header.Get("Cache-Control"); got != revalidateCacheControl {
+		t.Errorf("unversioned logo Cache-Control = %q, want %q", got, revalidateCacheControl)
+	}
+
+	if got := get("/branding/logo/light?v="+contentHash(logo)[:brandingAssetVersionLength], nil).Header.Get("Cache-Control"); got != versionedAssetCacheControl {
+		t.Errorf("versioned logo Cache-Control = %q, want %q", got, versionedAssetCacheControl)
+	}
+
+	configResp := get("/config", nil)
+	configETag := configResp.Header.Get("ETag")
+	if configResp.StatusCode != http.StatusOK || configETag == "" {
+		t.Fatalf("config: status = %d, ETag = %q", configResp.StatusCode, configETag)
+	}
+	if got, want := configResp.Header.Get("Vary"), "Authorization, "+serverHeader; got != want {
+		t.Errorf("config Vary = %q, want %q", got, want)
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name   string
+		target string
+		header map[string]string
+		status int
+	}{
+		{"logo etag", "/branding/logo/light", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"logo weak etag", "/branding/logo/light", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"logo other etag", "/branding/logo/light", map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{"logo modified since", "/branding/logo/light", map[string]string{"If-Modified-Since": future}, http.StatusNotModified},
+		{"logo etag wins", "/branding/logo/light", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": future}, http.StatusOK},
+		{"logo variant", "/branding/logo/light?width=64", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"config etag", "/config", map[string]string{"If-None-Match": configETag}, http.StatusNotModified},
+		{"config modified since", "/config", map[string]string{"If-Modified-Since": "Mon, 02 Jan 2006 15:04:05 GMT"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := get(tt.target, tt.header).StatusCode; got != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.status)
+		}
+	}
+}
@@ -1,16 +1,42 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"log"
+	"mime"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"pelagica-backend/models"
+	"pelagica-backend/persist"
+	"pelagica-backend/services"
 
 	"github.com/gofiber/fiber/v3"
 )
 
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// configMu serializes read-modify-write cycles on config.json.
+var configMu sync.Mutex
+
+// cachedConfig holds the last config content that parsed successfully. It
+// is only used while the file watcher keeps it in sync with the disk.
+var cachedConfig = struct {
+	mu      sync.RWMutex
+	enabled bool
+	data    []byte
+}{}
+
 func configPath() string {
 	path := os.Getenv("CONFIG_PATH")
 	if path == "" {
@@ -19,7 +45,247 @@ func configPath() string {
 	return path
 }
 
+// ifMatchSatisfied reports whether the If-Match request header, if any,
+// matches the current config content.
+func ifMatchSatisfied(c fiber.Ctx, current []byte) bool {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return true
+	}
+
+	etag := contentETag(current)
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimSpace(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func getCachedConfig() []byte {
+	cachedConfig.mu.RLock()
+	defer cachedConfig.mu.RUnlock()
+
+	return cachedConfig.data
+}
+
+// configModifiedAt is when the config or any overlay merged into it last
+// changed, in Unix nanoseconds. It starts at process start so the first
+// responses are never reported older than they may be.
+var configModifiedAt atomic.Int64
+
+func init() {
+	markConfigModified()
+}
+
+func markConfigModified() {
+	configModifiedAt.Store(time.Now().UnixNano())
+}
+
+// configLastModified returns when anything GetConfig serves last changed,
+// also covering edits to config.json the watcher has not picked up.
+func configLastModified() time.Time {
+	modified := time.Unix(0, configModifiedAt.Load())
+	if info, err := os.Stat(configPath()); err == nil && info.ModTime().After(modified) {
+		modified = info.ModTime()
+	}
+	return modified
+}
+
+func setCachedConfig(data []byte) {
+	cachedConfig.mu.Lock()
+	defer cachedConfig.mu.Unlock()
+
+	if cachedConfig.enabled {
+		cachedConfig.data = data
+	}
+	markConfigModified()
+}
+
+// ReloadConfig re-reads config.json into the config cache. If the file does
+// not parse, the last good config is kept and the error is logged.
+func ReloadConfig() {
+	data, err := os.ReadFile(configPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			setCachedConfig(nil)
+			return
+		}
+		log.Println("Error reloading config file, keeping last good config:", err)
+		return
+	}
+
+	var cfg models.AppConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		log.Println("Error parsing config file, keeping last good config:", err)
+		return
+	}
+
+	previous := getCachedConfig()
+	if bytes.Equal(previous, data) {
+		return
+	}
+
+	setCachedConfig(data)
+	log.Println("Config reloaded from disk")
+
+	publishConfigChange(previous, data)
+}
+
+// readConfigFile returns the current config content, or an empty object
+// when no config has been written yet.
+func readConfigFile() ([]byte, error) {
+	if data := getCachedConfig(); data != nil {
+		return data, nil
+	}
+
+	data, err := os.ReadFile(configPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []byte(`{}`), nil
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// writeConfigFile replaces config.json and records the new content as a
+// revision in the config history.
+func writeConfigFile(data []byte) error {
+	previous, err := readConfigFile()
+	if err != nil {
+		previous = nil
+	}
+
+	if err := persist.WriteFileWithBackup(configPath(), data, 0644); err != nil {
+		return err
+	}
+
+	setCachedConfig(data)
+
+	if configHistory != nil {
+		if _, err := configHistory.Record(data); err != nil {
+			log.Println("Error recording config revision:", err)
+		}
+	}
+
+	publishConfigChange(previous, data)
+
+	return nil
+}
+
+// publishConfigChange notifies event subscribers that the config changed,
+// and separately when the server theme was switched.
+func publishConfigChange(previous, current []byte) {
+	eventBus.Publish(models.EventConfigUpdated, fiber.Map{"etag": contentETag(current)})
+
+	var before, after models.AppConfig
+	json.Unmarshal(previous, &before)
+	if err := json.Unmarshal(current, &after); err != nil {
+		return
+	}
+
+	if before.ServerThemeId != after.ServerThemeId {
+		eventBus.Publish(models.EventServerThemeChanged, fiber.Map{"themeId": after.ServerThemeId})
+	}
+}
+
+// encodeConfig backfills slices the frontend expects to be present, stamps
+// the current config version and returns the config formatted for writing
+// to disk.
+func encodeConfig(cfg models.AppConfig) ([]byte, error) {
+	cfg.ConfigVersion = services.CurrentConfigVersion()
+
+	if cfg.ItemPage != nil {
+		if cfg.ItemPage.FavoriteButton == nil {
+			cfg.ItemPage.FavoriteButton = []models.BaseItemKind{}
+		}
+		if cfg.ItemPage.DeleteButton == nil {
+			cfg.ItemPage.DeleteButton = []models.BaseItemKind{}
+		}
+		if cfg.ItemPage.DetailBadges == nil {
+			cfg.ItemPage.DetailBadges = []models.DetailBadge{}
+		}
+	}
+
+	return json.MarshalIndent(cfg, "", "    ")
+}
+
+// MigrateConfigFile upgrades config.json to the current config version. The
+// original file is kept as config.json.v<version>.bak.
+func MigrateConfigFile() {
+	data, err := os.ReadFile(configPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("Error reading config file for migration:", err)
+		}
+		return
+	}
+
+	migrated, from, err := services.MigrateConfig(data)
+	if err != nil {
+		log.Println("Error migrating config file, leaving it untouched:", err)
+		return
+	}
+	if from == services.CurrentConfigVersion() {
+		return
+	}
+
+	var cfg models.AppConfig
+	if err := json.Unmarshal(migrated, &cfg); err != nil {
+		log.Println("Migrated config does not decode, leaving it untouched:", err)
+		return
+	}
+
+	encoded, err := encodeConfig(cfg)
+	if err != nil {
+		log.Println("Error encoding migrated config:", err)
+		return
+	}
+
+	backupPath := configPath() + ".v" + strconv.Itoa(from) + persist.BackupSuffix
+	if err := persist.WriteFile(backupPath, data, 0644); err != nil {
+		log.Println("Error backing up config before migration, leaving it untouched:", err)
+		return
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	if err := writeConfigFile(encoded); err != nil {
+		log.Println("Error writing migrated config:", err)
+		return
+	}
+
+	log.Printf("Config migrated from version %d to %d (backup: %s)", from, services.CurrentConfigVersion(), backupPath)
+}
+
+// isGlobalScope reports whether the request asked for the global config
+// with ?scope=global, leaving out server and user overlays.
+func isGlobalScope(c fiber.Ctx) bool {
+	return c.Query("scope") == "global"
+}
+
 func GetConfig(c fiber.Ctx) error {
+	// The served config depends on the caller and the server they picked.
+	c.Set(fiber.HeaderVary, "Authorization, "+serverHeader)
+
+	// The global config is what UpdateConfig and PatchConfig edit, so its
+	// ETag must be the one their If-Match is checked against.
+	if isGlobalScope(c) {
+		data, err := readConfigFile()
+		if err != nil {
+			log.Println("Error reading config file:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read config file"})
+		}
+		return sendConditionalJSON(c, data, configLastModified())
+	}
+
+	if data := getCachedConfig(); data != nil {
+		data = versionLogoURLs(applyCallerOverlay(c, applyServerConfig(c, data)))
+		return sendConditionalJSON(c, data, configLastModified())
+	}
+
 	path := configPath()
 
 	data, err := os.ReadFile(path)
@@ -32,7 +298,7 @@ func GetConfig(c fiber.Ctx) error {
 				return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to create config directory"})
 			}
 
-			if err := os.WriteFile(path, defaultConfig, 0644); err != nil {
+			if err := persist.WriteFile(path, defaultConfig, 0644); err != nil {
 				log.Println("Error creating config file:", err)
 				return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save config"})
 			}
@@ -44,11 +310,12 @@ func GetConfig(c fiber.Ctx) error {
 		}
 	}
 
-	return c.Status(fiber.StatusOK).
-		Type("json").
-		Send(data)
+	data = versionLogoURLs(applyCallerOverlay(c, applyServerConfig(c, data)))
+	return sendConditionalJSON(c, data, configLastModified())
 }
 
+// UpdateConfig replaces the global config. Server and user overlays are
+// edited through their own endpoints.
 func UpdateConfig(c fiber.Ctx) error {
 	var cfg models.AppConfig
 
@@ -57,28 +324,109 @@ func UpdateConfig(c fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid config"})
 	}
 
-	if cfg.ItemPage != nil {
-		if cfg.ItemPage.FavoriteButton == nil {
-			cfg.ItemPage.FavoriteButton = []models.BaseItemKind{}
-		}
-		if cfg.ItemPage.DeleteButton == nil {
-			cfg.ItemPage.DeleteButton = []models.BaseItemKind{}
-		}
-		if cfg.ItemPage.DetailBadges == nil {
-			cfg.ItemPage.DetailBadges = []models.DetailBadge{}
+	if fieldErrors := cfg.Validate(); len(fieldErrors) > 0 {
+		log.Printf("Config validation failed with %d error(s)", len(fieldErrors))
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid config", Fields: fieldErrors})
+	}
+
+	data, err := encodeConfig(cfg)
+	if err != nil {
+		log.Println("Error encoding config:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to encode config"})
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	current, err := readConfigFile()
+	if err != nil {
+		log.Println("Error reading config file:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read config file"})
+	}
+
+	if !ifMatchSatisfied(c, current) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(models.APIError{Error: "Config has been modified since it was loaded"})
+	}
+
+	if err := auditedWriteConfigFile(c, models.AuditConfigUpdate, "config", data); err != nil {
+		log.Println("Error writing config file:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save config"})
+	}
+
+	c.Set(fiber.HeaderETag, contentETag(data))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// PatchConfig applies a JSON Merge Patch (RFC 7396) or a JSON Patch
+// (RFC 6902) to the stored config, depending on the request content type.
+func PatchConfig(c fiber.Ctx) error {
+	mediaType, _, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	if err != nil {
+		mediaType = ""
+	}
+
+	var apply func(doc, patch []byte) ([]byte, error)
+	switch mediaType {
+	case mergePatchContentType, fiber.MIMEApplicationJSON:
+		apply = services.ApplyMergePatch
+	case jsonPatchContentType:
+		apply = services.ApplyJSONPatch
+	default:
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(models.APIError{Error: "Content-Type must be " + mergePatchContentType + " or " + jsonPatchContentType})
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	current, err := readConfigFile()
+	if err != nil {
+		log.Println("Error reading config file:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read config file"})
+	}
+
+	if !ifMatchSatisfied(c, current) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(models.APIError{Error: "Config has been modified since it was loaded"})
+	}
+
+	patched, err := apply(current, c.Body())
+	if err != nil {
+		log.Println("Error applying config patch:", err)
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Failed to apply patch: " + err.Error()})
+	}
+
+	var cfg models.AppConfig
+	if err := json.Unmarshal(patched, &cfg); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.APIError{
+				Error:  "Invalid config",
+				Fields: []models.FieldError{{Path: "/" + strings.ReplaceAll(typeErr.Field, ".", "/"), Message: "must be of type " + typeErr.Type.String()}},
+			})
 		}
+		log.Println("Error decoding patched config:", err)
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid config"})
 	}
 
-	data, err := json.MarshalIndent(cfg, "", "    ")
+	if fieldErrors := cfg.Validate(); len(fieldErrors) > 0 {
+		log.Printf("Config validation failed with %d error(s)", len(fieldErrors))
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid config", Fields: fieldErrors})
+	}
+
+	data, err := encodeConfig(cfg)
 	if err != nil {
 		log.Println("Error encoding config:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to encode config"})
 	}
 
-	if err := os.WriteFile(configPath(), data, 0644); err != nil {
+	if err := auditedWriteConfigFile(c, models.AuditConfigPatch, "config", data); err != nil {
 		log.Println("Error writing config file:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save config"})
 	}
 
+	c.Set(fiber.HeaderETag, contentETag(data))
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+func GetConfigSchema(c fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(services.ConfigSchema())
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	defaultConfigHistoryMaxRevisions = 50
+	configHistorySubdir              = "config-history"
+
+	// defaultConfigHistoryMaxAge keeps revisions regardless of their age.
+	defaultConfigHistoryMaxAge time.Duration = 0
+)
+
+var configHistory *services.ConfigHistory
+
+func configHistoryDir() string {
+	return filepath.Join(filepath.Dir(configPath()), configHistorySubdir)
+}
+
+func InitConfigHistory() {
+	history, err := services.NewConfigHistory(
+		configHistoryDir(),
+		fromEnv("CONFIG_HISTORY_MAX_REVISIONS", defaultConfigHistoryMaxRevisions),
+		positiveFromEnv("CONFIG_HISTORY_MAX_AGE", defaultConfigHistoryMaxAge),
+	)
+	if err != nil {
+		panic(err)
+	}
+	configHistory = history
+
+	// Seed an empty history with the config as it is before the first write,
+	// so the original config can always be rolled back to.
+	revisions, err := history.List()
+	if err != nil || len(revisions) > 0 {
+		return
+	}
+	data, err := os.ReadFile(configPath())
+	if err != nil || len(data) == 0 {
+		return
+	}
+	if _, err := history.Record(data); err != nil {
+		log.Println("Error recording initial config revision:", err)
+	}
+}
+
+func GetConfigRevisions(c fiber.Ctx) error {
+	revisions, err := configHistory.List()
+	if err != nil {
+		log.Println("Error listing config revisions:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to list config revisions"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(revisions)
+}
+
+func GetConfigRevision(c fiber.Ctx) error {
+	id := c.Params("id", "")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Revision ID is required"})
+	}
+
+	data, err := configHistory.Get(id)
+	if err != nil {
+		log.Println("Error retrieving config revision:", err)
+		return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Revision not found"})
+	}
+
+	return c.Status(fiber.StatusOK).
+		Type("json").
+		Send(data)
+}
+
+// DiffConfigRevisions compares the revisions given by the from and to query
+// parameters. When to is omitted the current config is used.
+func DiffConfigRevisions(c fiber.Ctx) error {
+	fromID := strings.TrimSpace(c.Query("from"))
+	if fromID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "from query parameter is required"})
+	}
+
+	from, err := configHistory.Get(fromID)
+	if err != nil {
+		log.Println("Error retrieving config revision:", err)
+		return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Revision not found: " + fromID})
+	}
+
+	var to []byte
+	if toID := strings.TrimSpace(c.Query("to")); toID != "" {
+		to, err = configHistory.Get(toID)
+		if err != nil {
+			log.Println("Error retrieving config revision:", err)
+			return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Revision not found: " + toID})
+		}
+	} else {
+		to, err = os.ReadFile(configPath())
+		if err != nil && !os.IsNotExist(err) {
+			log.Println("Error reading config file:", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read config file"})
+		}
+	}
+
+	changes, err := services.DiffJSON(from, to)
+	if err != nil {
+		log.Println("Error diffing config revisions:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to diff config revisions"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(changes)
+}
+
+func RollbackConfig(c fiber.Ctx) error {
+	id := c.Params("id", "")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Revision ID is required"})
+	}
+
+	data, err := configHistory.Get(id)
+	if err != nil {
+		log.Println("Error retrieving config revision:", err)
+		return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Revision not found"})
+	}
+
+	data, _, err = services.MigrateConfig(data)
+	if err != nil {
+		log.Println("Error migrating config revision:", err)
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.APIError{Error: "Revision does not contain a valid config"})
+	}
+
+	var cfg models.AppConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		log.Println("Error decoding config revision:", err)
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.APIError{Error: "Revision does not contain a valid config"})
+	}
+
+	if fieldErrors := cfg.Validate(); len(fieldErrors) > 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.APIError{Error: "Revision does not contain a valid config", Fields: fieldErrors})
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	data, err = encodeConfig(cfg)
+	if err != nil {
+		log.Println("Error encoding config:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to encode config"})
+	}
+
+	if err := auditedWriteConfigFile(c, models.AuditConfigRollback, "revision/"+id, data); err != nil {
+		log.Println("Error writing config file:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save config"})
+	}
+
+	log.Printf("Config rolled back to revision %s", id)
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestInitConfigHistoryRecordsOriginalConfig(t *testing.T) {
+	setupTestEnv(t)
+
+	original := []byte(`{"serverName":"Original"}`)
+	if err := os.WriteFile(configPath(), original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	InitConfigHistory()
+	t.Cleanup(func() { configHistory = nil })
+	if err := writeConfigFile([]byte(`{"serverName":"Changed"}`)); err != nil {
+		t.Fatalf("writeConfigFile: %v", err)
+	}
+
+	revisions, err := configHistory.List()
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("revisions = %+v (%v), want 2", revisions, err)
+	}
+	data, err := configHistory.Get(revisions[1].ID)
+	if err != nil || !bytes.Equal(data, original) {
+		t.Errorf("oldest revision = %s (%v), want the original config", data, err)
+	}
+
+	// Restarting with a history in place must not record the config again.
+	InitConfigHistory()
+	if revisions, _ := configHistory.List(); len(revisions) != 2 {
+		t.Errorf("revisions after restart = %d, want 2", len(revisions))
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pelagica-backend/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestPatchConfig(t *testing.T) {
+	setupTestEnv(t)
+
+	if err := os.WriteFile(configPath(), []byte(`{"serverName":"Before","links":[{"url":"https://a.example","text":"A","icon":"link"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/config", GetConfig)
+	app.Patch("/config", PatchConfig)
+
+	sendTo := func(method, target, contentType, ifMatch, body string) *http.Response {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s /config: %v", method, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	send := func(method, contentType, ifMatch, body string) *http.Response {
+		return sendTo(method, "/config?scope=global", contentType, ifMatch, body)
+	}
+
+	etag := send(http.MethodGet, "", "", "").Header.Get("ETag")
+
+	// Writes edit the global config without asking for its scope.
+	resp := sendTo(http.MethodPatch, "/config", mergePatchContentType, etag, `{"serverName":"After"}`)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("merge patch status = %d", resp.StatusCode)
+	}
+	newETag := resp.Header.Get("ETag")
+
+	if resp := send(http.MethodPatch, mergePatchContentType, etag, `{"serverName":"Stale"}`); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match status = %d, want %d", resp.StatusCode, http.StatusPreconditionFailed)
+	}
+
+	resp = send(http.MethodPatch, jsonPatchContentType, newETag, `[
+		{"op":"test","path":"/serverName","value":"After"},
+		{"op":"add","path":"/links/-","value":{"url":"https://b.example","text":"B","icon":"link"}},
+		{"op":"remove","path":"/links/0"}
+	]`)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("JSON patch status = %d", resp.StatusCode)
+	}
+
+	var cfg struct {
+		ServerName string `json:"serverName"`
+		Links      []struct {
+			Text string `json:"text"`
+		} `json:"links"`
+	}
+	decodeBody(t, send(http.MethodGet, "", "", ""), &cfg)
+	if cfg.ServerName != "After" || len(cfg.Links) != 1 || cfg.Links[0].Text != "B" {
+		t.Errorf("patched config = %+v", cfg)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"failed test operation", jsonPatchContentType, `[{"op":"test","path":"/serverName","value":"Other"}]`, http.StatusBadRequest},
+		{"wrong type", mergePatchContentType, `{"serverName":5}`, http.StatusBadRequest},
+		{"unsupported content type", "text/plain", `{}`, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		if got := send(http.MethodPatch, tt.contentType, "", tt.body).StatusCode; got != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.status)
+		}
+	}
+}
+
+func TestGetConfigVersionsLogoURLs(t *testing.T) {
+	setupTestEnv(t)
+
+	logoPath := brandingLogoPath("light")
+	os.MkdirAll(filepath.Dir(logoPath), 0755)
+	logo := []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"/>")
+	if err := os.WriteFile(logoPath, logo, 0644); err != nil {
+		t.Fatal(err)
+	}
+	stored := `{"logoLightUrl":"/api/branding/logo/light","logoDarkUrl":"/api/branding/logo/dark"}`
+	if err := os.WriteFile(configPath(), []byte(stored), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/config", GetConfig)
+
+	get := func(target string) models.AppConfig {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+		if err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+		defer resp.Body.Close()
+
+		var cfg models.AppConfig
+		if err := json.NewDecoder(resp.Body).Decode(&cfg); err != nil {
+			t.Fatalf("decoding %s: %v", target, err)
+		}
+		return cfg
+	}
+
+	cfg := get("/config")
+	if want := brandingLogoURL("light", contentHash(logo)); cfg.LogoLightURL != want {
+		t.Errorf("logoLightUrl = %q, want %q", cfg.LogoLightURL, want)
+	}
+	// There is no dark logo to version.
+	if cfg.LogoDarkURL != "/api/branding/logo/dark" {
+		t.Errorf("logoDarkUrl = %q, want it unchanged", cfg.LogoDarkURL)
+	}
+
+	// The global config is served as stored, as edits are checked against it.
+	if cfg := get("/config?scope=global"); cfg.LogoLightURL != "/api/branding/logo/light" {
+		t.Errorf("global logoLightUrl = %q, want it unchanged", cfg.LogoLightURL)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fromEnv returns the integer or duration set in the environment variable
+// key, or fallback when it is unset, does not parse or is negative.
+// Durations use time.ParseDuration syntax.
+func fromEnv[T int | time.Duration](key string, fallback T) T {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+
+	var value T
+	switch any(fallback).(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fallback
+		}
+		value = T(d)
+	default:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fallback
+		}
+		value = T(n)
+	}
+
+	if value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// positiveFromEnv is fromEnv for settings where zero makes no sense, such
+// as intervals and size limits; zero falls back as well.
+func positiveFromEnv[T int | time.Duration](key string, fallback T) T {
+	if value := fromEnv(key, fallback); value > 0 {
+		return value
+	}
+	return fallback
+}
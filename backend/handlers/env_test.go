@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		raw          string
+		wantInt      int
+		wantPositive time.Duration
+	}{
+		{"", 7, time.Minute},
+		{" 3 ", 3, time.Minute},
+		{"0", 0, time.Minute},
+		{"-1", 7, time.Minute},
+		{"abc", 7, time.Minute},
+		{"90s", 7, 90 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("TEST_SETTING", tt.raw)
+		if got := fromEnv("TEST_SETTING", 7); got != tt.wantInt {
+			t.Errorf("fromEnv(%q) = %d, want %d", tt.raw, got, tt.wantInt)
+		}
+		if got := positiveFromEnv("TEST_SETTING", time.Minute); got != tt.wantPositive {
+			t.Errorf("positiveFromEnv(%q) = %v, want %v", tt.raw, got, tt.wantPositive)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"time"
+
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const eventsHeartbeatInterval = 30 * time.Second
+
+var eventBus = services.NewEventBus()
+
+// GetEvents streams config and theme change notifications to the client as
+// Server-Sent Events.
+func GetEvents(c fiber.Ctx) error {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	events, unsubscribe := eventBus.Subscribe()
+
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		heartbeat := time.NewTicker(eventsHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		// Tell the client the stream is open before the first event arrives.
+		if _, err := w.WriteString(": connected\n\n"); err != nil || w.Flush() != nil {
+			return
+		}
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+
+				payload, err := json.Marshal(event)
+				if err != nil {
+					log.Println("Error encoding event:", err)
+					continue
+				}
+
+				w.WriteString("event: " + event.Type + "\n")
+				w.WriteString("data: " + string(payload) + "\n\n")
+			case <-heartbeat.C:
+				w.WriteString(": ping\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	defaultFacetsLimit = 100
+	maxFacetsLimit     = 1000
+)
+
+func parseFacetKind(c fiber.Ctx) (models.FacetKind, error) {
+	kind := models.FacetKind(strings.ToLower(c.Params("kind")))
+	if !slices.Contains(models.FacetKinds, kind) {
+		return "", errors.New("unknown facet kind: " + c.Params("kind"))
+	}
+	return kind, nil
+}
+
+// parseCommaList splits a comma separated query parameter, dropping empty
+// entries.
+func parseCommaList(raw string) []string {
+	values := []string{}
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func parseFacetFilter(c fiber.Ctx, kind models.FacetKind) (models.FacetFilter, error) {
+	filter := models.FacetFilter{
+		LibraryIDs: parseCommaList(c.Query("library")),
+		Role:       strings.TrimSpace(c.Query("role")),
+	}
+
+	for _, itemType := range parseCommaList(c.Query("type")) {
+		index := slices.IndexFunc(models.FacetItemTypes, func(t string) bool { return strings.EqualFold(t, itemType) })
+		if index < 0 {
+			return filter, errors.New("type must be one of " + strings.Join(models.FacetItemTypes, ", "))
+		}
+		filter.ItemTypes = append(filter.ItemTypes, models.FacetItemTypes[index])
+	}
+
+	if filter.Role != "" && kind != models.FacetPeople {
+		return filter, errors.New("role can only be used with the people facet")
+	}
+
+	return filter, nil
+}
+
+func parseFacetSort(c fiber.Ctx) (string, string, error) {
+	by := strings.ToLower(strings.TrimSpace(c.Query("sort", models.FacetSortCount)))
+	if !slices.Contains(models.FacetSortOptions, by) {
+		return "", "", errors.New("sort must be one of " + strings.Join(models.FacetSortOptions, ", "))
+	}
+
+	order := strings.ToLower(strings.TrimSpace(c.Query("order")))
+	switch order {
+	case "":
+		order = "asc"
+		if by == models.FacetSortCount {
+			order = "desc"
+		}
+	case "asc", "desc":
+	default:
+		return "", "", errors.New("order must be asc or desc")
+	}
+
+	return by, order, nil
+}
+
+func parseFacetPaging(c fiber.Ctx) (int, int, error) {
+	limit := defaultFacetsLimit
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			return 0, 0, errors.New("limit must be a number greater than 0")
+		}
+		limit = min(value, maxFacetsLimit)
+	}
+
+	startIndex := 0
+	if raw := strings.TrimSpace(c.Query("startIndex")); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return 0, 0, errors.New("startIndex must be a number of at least 0")
+		}
+		startIndex = value
+	}
+
+	return startIndex, limit, nil
+}
+
+// GetFacets counts the values of one facet kind (studios, genres, people,
+// ...) over the caller's Movies and Series, served from the studio index.
+func GetFacets(c fiber.Ctx) error {
+	kind, err := parseFacetKind(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
+	}
+
+	filter, err := parseFacetFilter(c, kind)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
+	}
+
+	by, order, err := parseFacetSort(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
+	}
+
+	startIndex, limit, err := parseFacetPaging(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
+	}
+
+	jellyfinURL, token, err := parseJellyfinCredentials(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
+	}
+
+	key, err := studioIndexKey(c, jellyfinURL, token)
+	if err != nil {
+		log.Printf("facets: failed resolving user: %v", err)
+		return c.Status(fiber.StatusBadGateway).JSON(models.APIError{Error: "Failed to load facets from Jellyfin: " + err.Error()})
+	}
+
+	values, err := studioIndex.Facet(key, token, kind, filter)
+	if err != nil {
+		log.Printf("facets: failed loading %s: %v", kind, err)
+		return c.Status(fiber.StatusBadGateway).JSON(models.APIError{Error: "Failed to load facets from Jellyfin: " + err.Error()})
+	}
+
+	// Cached values are shared between requests, so sort a copy.
+	values = slices.Clone(values)
+	services.SortFacetValues(values, by, order == "asc")
+
+	total := len(values)
+	start := min(startIndex, total)
+	end := min(start+limit, total)
+
+	return c.Status(fiber.StatusOK).JSON(models.FacetResponse{
+		Kind:        kind,
+		Sort:        by,
+		Order:       order,
+		SortOptions: models.FacetSortOptions,
+		Total:       total,
+		StartIndex:  start,
+		Items:       values[start:end],
+	})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"pelagica-backend/jellyfin/jellyfintest"
+	"pelagica-backend/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestGetFacets(t *testing.T) {
+	setupTestEnv(t)
+	jf := jellyfintest.NewServer(t)
+	app := fiber.New()
+	app.Get("/facets/:kind", GetFacets)
+
+	resp := doRequest(t, app, jf, "/facets/years?sort=name&order=desc&limit=2&startIndex=1", jellyfintest.UserToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var facets models.FacetResponse
+	decodeBody(t, resp, &facets)
+
+	if facets.Kind != models.FacetYears || facets.Sort != models.FacetSortName || facets.Order != "desc" {
+		t.Errorf("unexpected response metadata %+v", facets)
+	}
+	if facets.Total != 5 || facets.StartIndex != 1 || len(facets.Items) != 2 {
+		t.Fatalf("unexpected paging %+v", facets)
+	}
+	if facets.Items[0].Name != "2017" || facets.Items[1].Name != "2016" {
+		t.Errorf("unexpected items %+v", facets.Items)
+	}
+
+	for _, path := range []string{"/facets/colors", "/facets/genres?type=Episode", "/facets/genres?role=Actor", "/facets/genres?sort=random"} {
+		resp := doRequest(t, app, jf, path, jellyfintest.UserToken)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", path, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"pelagica-backend/jellyfin/jellyfintest"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// setupTestEnv points the config at a temp dir and initialises the stores
+// the handlers under test depend on.
+func setupTestEnv(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("CONFIG_PATH", filepath.Join(t.TempDir(), "config.json"))
+	t.Setenv("JELLYFIN_SERVER_URLS", "")
+	t.Setenv("JELLYFIN_BACKEND_URL", "")
+	t.Setenv("BRANDING_DIR", t.TempDir())
+	t.Setenv("STUDIO_THUMBS", t.TempDir())
+	t.Setenv("STUDIO_THUMB_MIRRORS", "")
+	t.Setenv("STUDIO_THUMBS_UPSTREAM", "false")
+
+	InitRoleStore()
+	InitServerStore()
+	InitStudioIndex()
+	InitAPIKeyStore()
+	InitStudioThumbSources()
+	InitThumbCache()
+}
+
+// doRequest sends a GET to path on app with the fake server as jellyfin_url
+// and token in the Authorization header.
+func doRequest(t *testing.T, app *fiber.App, jf *jellyfintest.Server, path, token string) *http.Response {
+	t.Helper()
+
+	target, err := url.Parse(path)
+	if err != nil {
+		t.Fatalf("parse %s: %v", path, err)
+	}
+	query := target.Query()
+	query.Set("jellyfin_url", jf.URL)
+	target.RawQuery = query.Encode()
+
+	req := httptest.NewRequest(http.MethodGet, target.String(), nil)
+	if token != "" {
+		req.Header.Set("Authorization", `MediaBrowser Token="`+token+`"`)
+	}
+
+	resp, err := app.Test(req, fiber.TestConfig{Timeout: 0})
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func decodeBody(t *testing.T, resp *http.Response, v any) {
+	t.Helper()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("decoding %q: %v", data, err)
+	}
+}
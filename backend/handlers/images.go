@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const imageFormatAuto = "auto"
+
+func parseImageDimension(c fiber.Ctx, key string) (int, error) {
+	raw := strings.TrimSpace(c.Query(key))
+	if raw == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		return 0, errors.New(key + " must be a number greater than 0")
+	}
+
+	return services.BoundImageVariantSize(value), nil
+}
+
+// parseImageVariant reads the width, height and format query parameters.
+// Sizes are rounded up to the allowed variant sizes. format=auto picks WebP
+// when the Accept header allows it and PNG otherwise.
+func parseImageVariant(c fiber.Ctx) (services.ImageVariant, error) {
+	var variant services.ImageVariant
+	var err error
+
+	if variant.Width, err = parseImageDimension(c, "width"); err != nil {
+		return variant, err
+	}
+	if variant.Height, err = parseImageDimension(c, "height"); err != nil {
+		return variant, err
+	}
+
+	switch format := strings.ToLower(strings.TrimSpace(c.Query("format"))); format {
+	case "":
+	case "jpg":
+		variant.Format = services.ImageFormatJPEG
+	case imageFormatAuto:
+		c.Vary(fiber.HeaderAccept)
+		variant.Format = services.ImageFormatPNG
+		if strings.Contains(c.Get(fiber.HeaderAccept), services.ImageFormatContentTypes[services.ImageFormatWebP]) {
+			variant.Format = services.ImageFormatWebP
+		}
+	default:
+		if _, ok := services.ImageFormatContentTypes[format]; !ok {
+			return variant, errors.New("format must be one of webp, png, jpeg or auto")
+		}
+		variant.Format = format
+	}
+
+	return variant, nil
+}
+
+// sendImage serves the image at path, or the variant of it requested by the
+// query, answering 304 when the client already has it. Images that cannot be
+// resized are served as they are.
+func sendImage(c fiber.Ctx, path, contentType string) error {
+	return sendImageVariant(c, path, contentType, services.RenderImageVariant)
+}
+
+// sendImageVariant is sendImage with the variant rendered by render.
+func sendImageVariant(c fiber.Ctx, path, contentType string, render func(string, services.ImageVariant) (string, string, error)) error {
+	variant, err := parseImageVariant(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: err.Error()})
+	}
+
+	if !variant.IsOriginal() {
+		variantPath, variantType, err := render(path, variant)
+		switch {
+		case err == nil:
+			path, contentType = variantPath, variantType
+		case errors.Is(err, services.ErrUnsupportedImage):
+		default:
+			log.Printf("Error rendering image variant of %s: %v", path, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to render image"})
+		}
+	}
+
+	return sendImageFile(c, path, contentType)
+}
+
+// sendImageFile serves the file at path as is, answering 304 when the
+// client already has it.
+func sendImageFile(c fiber.Ctx, path, contentType string) error {
+	if etag, modTime, err := fileETag(path); err == nil && notModified(c, etag, modTime) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	// SendFile would otherwise answer If-Modified-Since on its own, ignoring
+	// the If-None-Match header that takes precedence over it.
+	c.Request().Header.Del(fiber.HeaderIfModifiedSince)
+
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.SendFile(path)
+}
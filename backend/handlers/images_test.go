@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	_ "golang.org/x/image/webp"
+)
+
+func TestGetBrandingLogoVariants(t *testing.T) {
+	setupTestEnv(t)
+
+	var logo bytes.Buffer
+	png.Encode(&logo, image.NewNRGBA(image.Rect(0, 0, 300, 100)))
+	logoPath := brandingLogoPath("dark")
+	os.MkdirAll(filepath.Dir(logoPath), 0755)
+	if err := os.WriteFile(logoPath, logo.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Get("/branding/logo/:mode", GetBrandingLogo)
+
+	get := func(target, accept string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", target, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	tests := []struct {
+		target      string
+		accept      string
+		contentType string
+		width       int
+	}{
+		{"/branding/logo/dark", "", "image/png", 300},
+		{"/branding/logo/dark?width=100", "", "image/png", 128},
+		{"/branding/logo/dark?width=100&format=auto", "image/avif,image/webp,*/*", "image/webp", 128},
+		{"/branding/logo/dark?height=20&format=jpg", "", "image/jpeg", 96},
+	}
+	for _, tt := range tests {
+		resp := get(tt.target, tt.accept)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status = %d", tt.target, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: content type = %s, want %s", tt.target, got, tt.contentType)
+		}
+		config, _, err := image.DecodeConfig(resp.Body)
+		if err != nil || config.Width != tt.width {
+			t.Errorf("%s: width = %d (%v), want %d", tt.target, config.Width, err, tt.width)
+		}
+	}
+
+	for _, target := range []string{"/branding/logo/dark?width=-1", "/branding/logo/dark?format=gif"} {
+		if resp := get(target, ""); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", target, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// normalizeServerURL makes URLs comparable by lower-casing scheme and host
+// and dropping a trailing slash.
+func normalizeServerURL(raw string) (string, error) {
+	u, err := url.ParseRequestURI(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", errors.New("invalid server URL")
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	return u.String(), nil
+}
+
+// pinnedJellyfinURLs returns the Jellyfin servers the backend may talk to.
+// They come from the comma-separated JELLYFIN_SERVER_URLS and the server
+// registry, which only admins may change. The config's serverAddress is
+// deliberately not trusted: config managers could otherwise pin a server
+// that makes them administrators. An empty result means the server is not
+// pinned.
+func pinnedJellyfinURLs() []string {
+	pinned := []string{}
+
+	for _, raw := range strings.Split(os.Getenv("JELLYFIN_SERVER_URLS"), ",") {
+		if normalized, err := normalizeServerURL(raw); err == nil {
+			pinned = append(pinned, normalized)
+		}
+	}
+
+	for _, server := range serverStore.Get().Servers {
+		if normalized, err := normalizeServerURL(server.ServerAddress); err == nil {
+			pinned = append(pinned, normalized)
+		}
+	}
+
+	return pinned
+}
+
+// serverIDForURL returns the ID of the registered server at jellyfinURL, or
+// an empty ID for a server configured outside the registry. Permissions and
+// user config are kept per server ID, as user IDs are only unique per
+// server.
+func serverIDForURL(jellyfinURL string) string {
+	if server, ok := registeredServerForURL(jellyfinURL); ok {
+		return server.ID
+	}
+	return ""
+}
+
+// homeServerID returns the ID of the server used when a request names none.
+// Its administrators and roles manage the backend as a whole; those of
+// other registered servers only manage their own server.
+func homeServerID() string {
+	pinned := pinnedJellyfinURLs()
+	if len(pinned) == 0 {
+		return ""
+	}
+	return serverIDForURL(pinned[0])
+}
+
+// resolveJellyfinURL returns the Jellyfin server to validate the request
+// against. When servers are pinned, jellyfin_url is optional and must name
+// one of them; otherwise it is required and trusted as before.
+func resolveJellyfinURL(c fiber.Ctx) (string, error) {
+	server, ok, err := requestedServer(c)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return normalizeServerURL(server.ServerAddress)
+	}
+
+	requested := strings.TrimSpace(c.Query("jellyfin_url"))
+	pinned := pinnedJellyfinURLs()
+
+	if len(pinned) == 0 {
+		if requested == "" {
+			return "", errors.New("missing jellyfin_url query parameter")
+		}
+		if _, err := url.ParseRequestURI(requested); err != nil {
+			return "", errors.New("invalid jellyfin_url")
+		}
+		return requested, nil
+	}
+
+	if requested == "" {
+		return pinned[0], nil
+	}
+
+	normalized, err := normalizeServerURL(requested)
+	if err != nil {
+		return "", errors.New("invalid jellyfin_url")
+	}
+
+	for _, allowed := range pinned {
+		if normalized == allowed {
+			return allowed, nil
+		}
+	}
+
+	return "", errors.New("jellyfin_url is not an allowed Jellyfin server")
+}
+
+// resolveJellyfinBackendURL returns the URL the backend should use to reach
+// the Jellyfin server, which may differ from the public one. The
+// jellyfin_backend_url query parameter is ignored while servers are pinned.
+func resolveJellyfinBackendURL(c fiber.Ctx, jellyfinURL string) (string, error) {
+	if server, ok := registeredServerForURL(jellyfinURL); ok {
+		if server.BackendURL != "" {
+			return server.BackendURL, nil
+		}
+		return jellyfinURL, nil
+	}
+
+	override := strings.TrimSpace(c.Query("jellyfin_backend_url"))
+	if override != "" && len(pinnedJellyfinURLs()) > 0 {
+		override = ""
+	}
+	if override == "" {
+		override = strings.TrimSpace(os.Getenv("JELLYFIN_BACKEND_URL"))
+	}
+	if override == "" {
+		return jellyfinURL, nil
+	}
+
+	if _, err := url.ParseRequestURI(override); err != nil {
+		return "", errors.New("invalid jellyfin_backend_url")
+	}
+
+	return override, nil
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+var roleStore *services.RoleStore
+
+func rolesPath() string {
+	return filepath.Join(filepath.Dir(configPath()), "roles.json")
+}
+
+func InitRoleStore() {
+	store, err := services.NewRoleStore(rolesPath())
+	if err != nil {
+		panic(err)
+	}
+	roleStore = store
+}
+
+func GetRoles(c fiber.Ctx) error {
+	assignments := roleStore.Get()
+	if assignments.Groups == nil {
+		assignments.Groups = map[string][]string{}
+	}
+	if assignments.Roles == nil {
+		assignments.Roles = map[string]models.Role{}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(assignments)
+}
+
+func UpdateRoles(c fiber.Ctx) error {
+	var assignments models.RoleAssignments
+
+	if err := c.Bind().Body(&assignments); err != nil {
+		log.Println("Error decoding roles:", err)
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid roles"})
+	}
+
+	fieldErrors := assignments.Validate()
+	registry := serverStore.Get()
+	for _, name := range slices.Sorted(maps.Keys(assignments.Roles)) {
+		server := assignments.Roles[name].Server
+		if _, ok := registry.Find(server); server != "" && !ok {
+			fieldErrors = append(fieldErrors, models.FieldError{Path: "/roles/" + name + "/server", Message: "unknown server"})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid roles", Fields: fieldErrors})
+	}
+
+	previous, _ := json.Marshal(roleStore.Get())
+
+	if err := roleStore.Write(assignments); err != nil {
+		log.Println("Error writing roles:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save roles"})
+	}
+
+	log.Printf("Roles updated: %d role(s), %d group(s)", len(assignments.Roles), len(assignments.Groups))
+
+	updated, _ := json.Marshal(assignments)
+	changes, err := services.DiffJSON(previous, updated)
+	if err != nil {
+		log.Println("Error diffing roles for audit log:", err)
+	}
+	recordAudit(c, models.AuditEntry{Action: models.AuditRolesUpdate, Target: "roles", Changes: changes})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	serverHeader        = "X-Pelagica-Server"
+	serverConfigsSubdir = "server-configs"
+)
+
+var (
+	serverStore        *services.ServerStore
+	serverOverlayStore *services.OverlayStore
+)
+
+// serverLockedConfigPaths may not be overridden per server. serverAddress
+// is always taken from the server registry.
+var serverLockedConfigPaths = []string{"/$schema", "/configVersion", "/serverAddress"}
+
+func serversPath() string {
+	return filepath.Join(filepath.Dir(configPath()), "servers.json")
+}
+
+func InitServerStore() {
+	store, err := services.NewServerStore(serversPath())
+	if err != nil {
+		panic(err)
+	}
+	serverStore = store
+
+	overlays, err := services.NewOverlayStore(filepath.Join(filepath.Dir(configPath()), serverConfigsSubdir), models.IsServerID)
+	if err != nil {
+		panic(err)
+	}
+	serverOverlayStore = overlays
+}
+
+// registeredServerForURL returns the registered server whose address or
+// backend URL is rawURL.
+func registeredServerForURL(rawURL string) (models.JellyfinServer, bool) {
+	normalized, err := normalizeServerURL(rawURL)
+	if err != nil {
+		return models.JellyfinServer{}, false
+	}
+
+	for _, server := range serverStore.Get().Servers {
+		for _, candidate := range []string{server.ServerAddress, server.BackendURL} {
+			if address, err := normalizeServerURL(candidate); err == nil && address == normalized {
+				return server, true
+			}
+		}
+	}
+
+	return models.JellyfinServer{}, false
+}
+
+// requestedServer returns the registered server a request is for, selected
+// by ?server=, the X-Pelagica-Server header or a jellyfin_url matching a
+// server's address. ok is false for requests that don't name a registered
+// server.
+func requestedServer(c fiber.Ctx) (server models.JellyfinServer, ok bool, err error) {
+	id := strings.TrimSpace(c.Query("server"))
+	if id == "" {
+		id = strings.TrimSpace(c.Get(serverHeader))
+	}
+
+	if id != "" {
+		registry := serverStore.Get()
+		server, ok := registry.Find(id)
+		if !ok {
+			return models.JellyfinServer{}, false, errors.New("unknown server " + id)
+		}
+		return server, true, nil
+	}
+
+	if requested := strings.TrimSpace(c.Query("jellyfin_url")); requested != "" {
+		server, ok := registeredServerForURL(requested)
+		return server, ok, nil
+	}
+
+	return models.JellyfinServer{}, false, nil
+}
+
+// applyServerConfig merges the overlay of the requested server into the
+// global config and sets serverAddress to the server's address. Requests
+// without a registered server and ?scope=global get global unchanged.
+func applyServerConfig(c fiber.Ctx, global []byte) []byte {
+	if isGlobalScope(c) {
+		return global
+	}
+
+	server, ok, err := requestedServer(c)
+	if err != nil {
+		log.Println("Could not resolve server for config:", err)
+		return global
+	}
+	if !ok {
+		return global
+	}
+
+	merged := global
+
+	overlay, err := serverOverlayStore.Get(server.ID)
+	if err != nil {
+		log.Println("Error reading server config overlay:", err)
+	}
+	if overlay != nil {
+		if result, err := services.ApplyOverlay(global, overlay, serverLockedConfigPaths); err != nil {
+			log.Println("Error applying server config overlay:", err)
+		} else {
+			merged = result
+		}
+	}
+
+	address, _ := json.Marshal(map[string]string{"serverAddress": server.ServerAddress})
+	if result, err := services.ApplyMergePatch(merged, address); err == nil {
+		merged = result
+	}
+
+	return merged
+}
+
+func GetServers(c fiber.Ctx) error {
+	servers := []models.ServerSummary{}
+	for _, server := range serverStore.Get().Servers {
+		servers = append(servers, models.ServerSummary{ID: server.ID, Name: server.Name, ServerAddress: server.ServerAddress})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(servers)
+}
+
+func GetServerRegistry(c fiber.Ctx) error {
+	registry := serverStore.Get()
+	if registry.Servers == nil {
+		registry.Servers = []models.JellyfinServer{}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(registry)
+}
+
+func UpdateServerRegistry(c fiber.Ctx) error {
+	var registry models.ServerRegistry
+
+	if err := c.Bind().Body(&registry); err != nil {
+		log.Println("Error decoding servers:", err)
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid servers"})
+	}
+
+	if fieldErrors := registry.Validate(); len(fieldErrors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid servers", Fields: fieldErrors})
+	}
+
+	previous, _ := json.Marshal(serverStore.Get())
+
+	if err := serverStore.Write(registry); err != nil {
+		log.Println("Error writing servers:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save servers"})
+	}
+	markConfigModified()
+
+	log.Printf("Servers updated: %d server(s)", len(registry.Servers))
+
+	updated, _ := json.Marshal(registry)
+	changes, err := services.DiffJSON(previous, updated)
+	if err != nil {
+		log.Println("Error diffing servers for audit log:", err)
+	}
+	recordAudit(c, models.AuditEntry{Action: models.AuditServersUpdate, Target: "servers", Changes: changes})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func registeredServerParam(c fiber.Ctx) (models.JellyfinServer, bool) {
+	registry := serverStore.Get()
+	return registry.Find(c.Params("id", ""))
+}
+
+func GetServerConfig(c fiber.Ctx) error {
+	server, ok := registeredServerParam(c)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Server not found"})
+	}
+
+	overlay, err := serverOverlayStore.Get(server.ID)
+	if err != nil {
+		log.Println("Error reading server config overlay:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read server config"})
+	}
+	if overlay == nil {
+		overlay = []byte(`{}`)
+	}
+
+	return c.Status(fiber.StatusOK).
+		Type("json").
+		Send(overlay)
+}
+
+// UpdateServerConfig replaces the config overlay of a server. The overlay is
+// a JSON Merge Patch on top of the global config.
+func UpdateServerConfig(c fiber.Ctx) error {
+	server, ok := registeredServerParam(c)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Server not found"})
+	}
+
+	var overlay map[string]any
+	if err := json.Unmarshal(c.Body(), &overlay); err != nil || overlay == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Server config must be a JSON object"})
+	}
+
+	data, err := json.MarshalIndent(overlay, "", "    ")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid server config"})
+	}
+
+	touched, err := services.LockedOverlayPaths(data, serverLockedConfigPaths)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid server config"})
+	}
+	if len(touched) > 0 {
+		fieldErrors := make([]models.FieldError, 0, len(touched))
+		for _, path := range touched {
+			fieldErrors = append(fieldErrors, models.FieldError{Path: path, Message: "cannot be set per server"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid server config", Fields: fieldErrors})
+	}
+
+	global, err := readConfigFile()
+	if err != nil {
+		log.Println("Error reading config file:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read config file"})
+	}
+
+	merged, err := services.ApplyMergePatch(global, data)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid server config"})
+	}
+
+	var cfg models.AppConfig
+	if err := json.Unmarshal(merged, &cfg); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid server config"})
+	}
+	if fieldErrors := cfg.Validate(); len(fieldErrors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid server config", Fields: fieldErrors})
+	}
+
+	previous, err := serverOverlayStore.Get(server.ID)
+	if err != nil || previous == nil {
+		previous = []byte(`{}`)
+	}
+
+	if err := serverOverlayStore.Write(server.ID, data); err != nil {
+		log.Println("Error writing server config overlay:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save server config"})
+	}
+	markConfigModified()
+
+	log.Printf("Config for server %s updated", server.ID)
+	eventBus.Publish(models.EventConfigUpdated, fiber.Map{"server": server.ID})
+
+	changes, err := services.DiffJSON(previous, data)
+	if err != nil {
+		log.Println("Error diffing server config for audit log:", err)
+	}
+	recordAudit(c, models.AuditEntry{Action: models.AuditServerConfigUpdate, Target: server.ID, Changes: changes})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func DeleteServerConfig(c fiber.Ctx) error {
+	server, ok := registeredServerParam(c)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Server not found"})
+	}
+
+	if err := serverOverlayStore.Delete(server.ID); err != nil {
+		log.Println("Error deleting server config overlay:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to reset server config"})
+	}
+	markConfigModified()
+
+	log.Printf("Config for server %s reset", server.ID)
+	eventBus.Publish(models.EventConfigUpdated, fiber.Map{"server": server.ID})
+	recordAudit(c, models.AuditEntry{Action: models.AuditServerConfigReset, Target: server.ID})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func reloadServers() {
+	if err := serverStore.Reload(); err != nil {
+		log.Println("Error reloading servers, keeping last good servers:", err)
+		return
+	}
+	markConfigModified()
+	log.Println("Servers reloaded from disk")
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"pelagica-backend/jellyfin"
+	"pelagica-backend/jellyfin/jellyfintest"
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestStudiosArePartitionedPerServer(t *testing.T) {
+	setupTestEnv(t)
+	first := jellyfintest.NewServer(t)
+	second := jellyfintest.NewServer(t)
+	second.SetItems([]jellyfin.Item{
+		{ID: "movie-1", Type: "Movie", ParentID: jellyfintest.MoviesLibraryID, Studios: []jellyfin.NameIDPair{{ID: "studio-pixar", Name: "Pixar"}}},
+	})
+
+	err := serverStore.Write(models.ServerRegistry{Servers: []models.JellyfinServer{
+		{ID: "first", Name: "First", ServerAddress: first.URL},
+		{ID: "second", Name: "Second", ServerAddress: second.URL},
+	}})
+	if err != nil {
+		t.Fatalf("writing servers: %v", err)
+	}
+
+	app := newStudiosTestApp()
+
+	// Both fakes accept the same token; the server query picks the one used,
+	// regardless of jellyfin_url.
+	resp := doRequest(t, app, first, "/studios?server=second", jellyfintest.UserToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var studios []models.StudioSummary
+	decodeBody(t, resp, &studios)
+	if len(studios) != 1 || studios[0].Name != "Pixar" {
+		t.Errorf("second server studios = %+v", studios)
+	}
+
+	resp = doRequest(t, app, first, "/studios", jellyfintest.UserToken)
+	decodeBody(t, resp, &studios)
+	if len(studios) != 3 {
+		t.Errorf("first server studios = %+v", studios)
+	}
+
+	if resp := doRequest(t, app, first, "/studios?server=unknown", jellyfintest.UserToken); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown server status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestConfigIsScopedPerServer(t *testing.T) {
+	setupTestEnv(t)
+	jf := jellyfintest.NewServer(t)
+
+	err := serverStore.Write(models.ServerRegistry{Servers: []models.JellyfinServer{
+		{ID: "family", Name: "Family", ServerAddress: jf.URL},
+	}})
+	if err != nil {
+		t.Fatalf("writing servers: %v", err)
+	}
+	if err := serverOverlayStore.Write("family", []byte(`{"serverName": "Family Server"}`)); err != nil {
+		t.Fatalf("writing server config: %v", err)
+	}
+
+	app := fiber.New()
+	app.Get("/config", GetConfig)
+
+	var cfg models.AppConfig
+	decodeBody(t, doRequest(t, app, jf, "/config", ""), &cfg)
+	if cfg.ServerName != "Family Server" || cfg.ServerAddress != jf.URL {
+		t.Errorf("server config = name %q, address %q", cfg.ServerName, cfg.ServerAddress)
+	}
+
+	cfg = models.AppConfig{}
+	decodeBody(t, doRequest(t, app, jf, "/config?scope=global", ""), &cfg)
+	if cfg.ServerName != "" {
+		t.Errorf("global config server name = %q, want empty", cfg.ServerName)
+	}
+}
+
+func TestPermissionsArePerServer(t *testing.T) {
+	setupTestEnv(t)
+	first := jellyfintest.NewServer(t)
+	second := jellyfintest.NewServer(t)
+
+	err := serverStore.Write(models.ServerRegistry{Servers: []models.JellyfinServer{
+		{ID: "first", Name: "First", ServerAddress: first.URL},
+		{ID: "second", Name: "Second", ServerAddress: second.URL},
+	}})
+	if err != nil {
+		t.Fatalf("writing servers: %v", err)
+	}
+	err = roleStore.Write(models.RoleAssignments{Roles: map[string]models.Role{
+		"second-editors": {Server: "second", Permissions: []models.Permission{models.PermissionManageConfig}, Users: []string{jellyfintest.UserUserID}},
+	}})
+	if err != nil {
+		t.Fatalf("writing roles: %v", err)
+	}
+
+	app := fiber.New()
+	ok := func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) }
+	app.Get("/config-admin", RequirePermission(models.PermissionManageConfig), ok)
+	app.Get("/admin/servers/:id/config", RequireServerPermission(models.PermissionManageConfig), ok)
+
+	// Both fakes accept the same tokens and report the same user IDs, like
+	// unrelated servers may.
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"home server admin", "/config-admin?server=first", jellyfintest.AdminToken, http.StatusNoContent},
+		{"other server admin", "/config-admin?server=second", jellyfintest.AdminToken, http.StatusForbidden},
+		{"admin of the managed server", "/admin/servers/second/config?server=second", jellyfintest.AdminToken, http.StatusNoContent},
+		{"admin of another server", "/admin/servers/first/config?server=second", jellyfintest.AdminToken, http.StatusForbidden},
+		{"role on the managed server", "/admin/servers/second/config?server=second", jellyfintest.UserToken, http.StatusNoContent},
+		{"same user ID on another server", "/admin/servers/first/config?server=first", jellyfintest.UserToken, http.StatusForbidden},
+		{"role does not reach the backend", "/config-admin?server=second", jellyfintest.UserToken, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := doRequest(t, app, first, tt.path, tt.token); resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestUserConfigIsPerServer(t *testing.T) {
+	setupTestEnv(t)
+	InitUserOverlayStore()
+	first := jellyfintest.NewServer(t)
+	second := jellyfintest.NewServer(t)
+
+	err := serverStore.Write(models.ServerRegistry{Servers: []models.JellyfinServer{
+		{ID: "first", Name: "First", ServerAddress: first.URL},
+		{ID: "second", Name: "Second", ServerAddress: second.URL},
+	}})
+	if err != nil {
+		t.Fatalf("writing servers: %v", err)
+	}
+	key := services.UserOverlayKey("second", jellyfintest.UserUserID)
+	if err := userOverlayStore.Write(key, []byte(`{"serverName": "Mine"}`)); err != nil {
+		t.Fatalf("writing user config: %v", err)
+	}
+
+	app := fiber.New()
+	app.Get("/config", GetConfig)
+
+	var cfg models.AppConfig
+	decodeBody(t, doRequest(t, app, first, "/config?server=second", jellyfintest.UserToken), &cfg)
+	if cfg.ServerName != "Mine" {
+		t.Errorf("second server name = %q, want %q", cfg.ServerName, "Mine")
+	}
+
+	cfg = models.AppConfig{}
+	decodeBody(t, doRequest(t, app, first, "/config?server=first", jellyfintest.UserToken), &cfg)
+	if cfg.ServerName != "" {
+		t.Errorf("first server name = %q, want empty", cfg.ServerName)
+	}
+}
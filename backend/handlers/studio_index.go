@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pelagica-backend/jellyfin"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	defaultStudioIndexSchedule            = "@every 15m"
+	defaultStudioIndexFullRebuildInterval = 24 * time.Hour
+	defaultStudioIndexMaxAge              = 7 * 24 * time.Hour
+)
+
+var studioIndex *services.StudioIndex
+
+func studioIndexDir() string {
+	if dir := strings.TrimSpace(os.Getenv("STUDIO_INDEX_DIR")); dir != "" {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(configPath()), "studio-index")
+}
+
+// InitStudioIndex loads the persisted studio indexes. Indexes not rebuilt
+// within STUDIO_INDEX_MAX_AGE are dropped; 0 keeps them forever.
+func InitStudioIndex() {
+	index, err := services.NewStudioIndex(
+		studioIndexDir(),
+		positiveFromEnv("STUDIO_INDEX_FULL_REBUILD_INTERVAL", defaultStudioIndexFullRebuildInterval),
+		fromEnv("STUDIO_INDEX_MAX_AGE", defaultStudioIndexMaxAge),
+	)
+	if err != nil {
+		panic(err)
+	}
+	studioIndex = index
+}
+
+// RegisterStudioIndexJob refreshes all studio indexes on the
+// STUDIO_INDEX_SCHEDULE cron schedule.
+func RegisterStudioIndexJob() *cron.Cron {
+	schedule := strings.TrimSpace(os.Getenv("STUDIO_INDEX_SCHEDULE"))
+	if schedule == "" {
+		schedule = defaultStudioIndexSchedule
+	}
+
+	c := cron.New()
+	_, err := c.AddFunc(schedule, studioIndex.RefreshAll)
+	if err != nil {
+		log.Printf("Failed to register studio index job: %v", err)
+		return nil
+	}
+	c.Start()
+	log.Printf("Studio index job registered (%s)", schedule)
+	return c
+}
+
+// studioIndexKey resolves the caller's Jellyfin user and the server the
+// request targets into the key of their index.
+func studioIndexKey(c fiber.Ctx, jellyfinURL, token string) (services.StudioIndexKey, error) {
+	user, err := jellyfin.ResolveUser(jellyfinURL, token)
+	if err != nil {
+		return services.StudioIndexKey{}, err
+	}
+
+	server, _, _ := requestedServer(c)
+	return services.StudioIndexKey{ServerID: server.ID, JellyfinURL: jellyfinURL, UserID: user.Id}, nil
+}
+
+func GetStudioIndexStatus(c fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(studioIndex.Status())
+}
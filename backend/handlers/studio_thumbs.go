@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	maxStudioThumbSizeBytes             int64 = 5 * 1024 * 1024
+	defaultThumbCacheDir                      = "./cache/studio-thumbs"
+	defaultThumbCacheMaxBytes                 = 256 * 1024 * 1024
+	defaultThumbCacheRevalidateInterval       = 7 * 24 * time.Hour
+	defaultThumbCacheNegativeTTL              = time.Hour
+)
+
+var (
+	thumbSources *services.ThumbSources
+	thumbCache   *services.ThumbCache
+)
+
+func studioThumbsUploadDir() string {
+	if dir := strings.TrimSpace(os.Getenv("STUDIO_THUMBS_UPLOAD_DIR")); dir != "" {
+		return dir
+	}
+	return filepath.Join(brandingDir(), "studio-thumbs")
+}
+
+// studioThumbRemoteURLs returns the remote catalogs in lookup order: the
+// comma separated STUDIO_THUMB_MIRRORS, then the upstream catalog unless
+// STUDIO_THUMBS_UPSTREAM is false.
+func studioThumbRemoteURLs() []string {
+	urls := parseCommaList(os.Getenv("STUDIO_THUMB_MIRRORS"))
+
+	upstream := true
+	if raw := strings.TrimSpace(os.Getenv("STUDIO_THUMBS_UPSTREAM")); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			log.Printf("Invalid STUDIO_THUMBS_UPSTREAM %q, using the upstream catalog", raw)
+		} else {
+			upstream = value
+		}
+	}
+	if upstream {
+		urls = append(urls, services.UpstreamThumbsBaseURL)
+	}
+
+	return urls
+}
+
+func InitStudioThumbSources() {
+	local, err := services.NewLocalThumbStore(studioThumbsUploadDir())
+	if err != nil {
+		panic(err)
+	}
+
+	ttl := positiveFromEnv("STUDIO_THUMBS_CACHE_TTL", defaultThumbsCacheTTL)
+	sources := &services.ThumbSources{Local: local}
+	for _, raw := range studioThumbRemoteURLs() {
+		remote, err := services.NewRemoteThumbSource(raw, ttl)
+		if err != nil {
+			log.Printf("Skipping studio thumb source: %v", err)
+			continue
+		}
+		sources.Remotes = append(sources.Remotes, remote)
+	}
+	thumbSources = sources
+}
+
+func thumbCacheDir() string {
+	if dir := strings.TrimSpace(os.Getenv("STUDIO_THUMBS")); dir != "" {
+		return dir
+	}
+	return defaultThumbCacheDir
+}
+
+// InitThumbCache sets up the cache of downloaded thumbs. STUDIO_THUMBS_MAX_BYTES
+// of 0 disables the size limit.
+func InitThumbCache() {
+	cache, err := services.NewThumbCache(
+		thumbCacheDir(),
+		int64(fromEnv("STUDIO_THUMBS_MAX_BYTES", defaultThumbCacheMaxBytes)),
+		positiveFromEnv("STUDIO_THUMBS_REVALIDATE_INTERVAL", defaultThumbCacheRevalidateInterval),
+		positiveFromEnv("STUDIO_THUMBS_NEGATIVE_TTL", defaultThumbCacheNegativeTTL),
+	)
+	if err != nil {
+		panic(err)
+	}
+	thumbCache = cache
+}
+
+func studioNameParam(c fiber.Ctx) string {
+	raw := strings.TrimSpace(c.Params("name"))
+	name, err := url.PathUnescape(raw)
+	if err != nil {
+		name = raw
+	}
+	return strings.TrimSpace(name)
+}
+
+func GetStudioThumbUploads(c fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(thumbSources.Local.List())
+}
+
+func UploadStudioThumb(c fiber.Ctx) error {
+	studioName := studioNameParam(c)
+	if studioName == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Studio name is required"})
+	}
+
+	fileHeader, err := c.FormFile("thumb")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Missing thumb file"})
+	}
+
+	if fileHeader.Size > maxStudioThumbSizeBytes {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Thumb file is too large (max 5MB)"})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Failed to read uploaded file"})
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Failed to read uploaded file"})
+	}
+
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Uploaded file must be an image"})
+	}
+
+	thumb, err := thumbSources.Local.Save(studioName, data, contentType)
+	if err != nil {
+		log.Println("Error saving studio thumb:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save studio thumb"})
+	}
+
+	recordAudit(c, models.AuditEntry{Action: models.AuditStudioThumbUpload, Target: thumb.Name})
+	eventBus.Publish(models.EventStudioThumbChanged, fiber.Map{"name": thumb.Name, "source": thumb.Source})
+
+	return c.Status(fiber.StatusOK).JSON(thumb)
+}
+
+func DeleteStudioThumb(c fiber.Ctx) error {
+	studioName := studioNameParam(c)
+	if studioName == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Studio name is required"})
+	}
+
+	if err := thumbSources.Local.Delete(studioName); err != nil {
+		if errors.Is(err, services.ErrStudioThumbNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Studio thumb not found"})
+		}
+		log.Println("Error deleting studio thumb:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to delete studio thumb"})
+	}
+
+	recordAudit(c, models.AuditEntry{Action: models.AuditStudioThumbDelete, Target: studioName})
+	eventBus.Publish(models.EventStudioThumbChanged, fiber.Map{"name": studioName})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func GetThumbCacheStats(c fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(thumbCache.Stats())
+}
+
+// PurgeThumbCache empties the thumb cache, or only drops the thumbs of the
+// studio given by the name parameter.
+func PurgeThumbCache(c fiber.Ctx) error {
+	target := "*"
+	var removed int
+	if studioName := studioNameParam(c); studioName != "" {
+		target = studioName
+		removed = thumbCache.PurgeStudio(studioName)
+	} else {
+		removed = thumbCache.Purge()
+	}
+
+	recordAudit(c, models.AuditEntry{Action: models.AuditThumbCachePurge, Target: target, Details: fiber.Map{"removed": removed}})
+	log.Printf("Thumb cache purged (%s, %d thumbs removed)", target, removed)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"removed": removed})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pelagica-backend/jellyfin/jellyfintest"
+	"pelagica-backend/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// pngHeader is enough of a PNG for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func newThumbMirror(t *testing.T, thumbs map[string][]byte) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /thumbs.txt", func(w http.ResponseWriter, r *http.Request) {
+		for name := range thumbs {
+			io.WriteString(w, name+"\n")
+		}
+	})
+	mux.HandleFunc("GET /{name}/thumb.webp", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := thumbs[r.PathValue("name")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+
+	mirror := httptest.NewServer(mux)
+	t.Cleanup(mirror.Close)
+	return mirror
+}
+
+func uploadStudioThumb(t *testing.T, app *fiber.App, name string, data []byte) *http.Response {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("thumb", "thumb.png")
+	if err != nil {
+		t.Fatalf("creating form: %v", err)
+	}
+	part.Write(data)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/studios/"+name+"/thumb", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := app.Test(req, fiber.TestConfig{Timeout: 0})
+	if err != nil {
+		t.Fatalf("POST thumb: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestStudioThumbSourceChain(t *testing.T) {
+	setupTestEnv(t)
+	mirror := newThumbMirror(t, map[string][]byte{"A24": []byte("mirror-a24"), "HBO": []byte("mirror-hbo")})
+	t.Setenv("STUDIO_THUMB_MIRRORS", mirror.URL)
+	InitStudioThumbSources()
+
+	jf := jellyfintest.NewServer(t)
+	app := fiber.New()
+	app.Get("/studios", GetStudios)
+	app.Get("/studios/:name/thumb", GetStudioThumb)
+	app.Post("/studios/:name/thumb", UploadStudioThumb)
+	app.Delete("/studios/:name/thumb", DeleteStudioThumb)
+
+	if resp := uploadStudioThumb(t, app, "Studio%20Ghibli", []byte("not an image")); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("non-image upload status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	for _, name := range []string{"Studio%20Ghibli", "HBO"} {
+		if resp := uploadStudioThumb(t, app, name, pngHeader); resp.StatusCode != http.StatusOK {
+			t.Fatalf("upload %s status = %d", name, resp.StatusCode)
+		}
+	}
+
+	resp := doRequest(t, app, jf, "/studios?hasThumb=true", jellyfintest.UserToken)
+	var studios []models.StudioSummary
+	decodeBody(t, resp, &studios)
+	if len(studios) != 3 {
+		t.Errorf("studios with thumbs = %+v, want all three", studios)
+	}
+
+	thumb := func(name string) (int, string) {
+		resp := doRequest(t, app, jf, "/studios/"+name+"/thumb", "")
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	// Uploads take priority over the mirror.
+	if status, data := thumb("HBO"); status != http.StatusOK || data != string(pngHeader) {
+		t.Errorf("HBO thumb = %d %q, want the upload", status, data)
+	}
+	if status, data := thumb("A24"); status != http.StatusOK || data != "mirror-a24" {
+		t.Errorf("A24 thumb = %d %q, want the mirror thumb", status, data)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/studios/HBO/thumb", nil)
+	if resp, err := app.Test(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE thumb = %v, %v", resp, err)
+	}
+	if status, data := thumb("HBO"); status != http.StatusOK || data != "mirror-hbo" {
+		t.Errorf("HBO thumb after delete = %d %q, want the mirror thumb", status, data)
+	}
+	if status, _ := thumb("Pixar"); status != http.StatusNotFound {
+		t.Errorf("Pixar thumb status = %d, want %d", status, http.StatusNotFound)
+	}
+}
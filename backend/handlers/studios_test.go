@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"pelagica-backend/jellyfin"
+	"pelagica-backend/jellyfin/jellyfintest"
+	"pelagica-backend/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func newStudiosTestApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/studios", GetStudios)
+	return app
+}
+
+func TestGetStudios(t *testing.T) {
+	setupTestEnv(t)
+	jf := jellyfintest.NewServer(t)
+	app := newStudiosTestApp()
+
+	resp := doRequest(t, app, jf, "/studios", jellyfintest.UserToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var studios []models.StudioSummary
+	decodeBody(t, resp, &studios)
+
+	want := []models.StudioSummary{
+		{ID: "studio-a24", Name: "A24", Count: 3},
+		{ID: "studio-hbo", Name: "HBO", Count: 2},
+		{ID: "studio-ghibli", Name: "Studio Ghibli", Count: 2},
+	}
+	if len(studios) != len(want) {
+		t.Fatalf("got %d studios, want %d: %+v", len(studios), len(want), studios)
+	}
+	for i := range want {
+		if studios[i] != want[i] {
+			t.Errorf("studio %d = %+v, want %+v", i, studios[i], want[i])
+		}
+	}
+
+	resp = doRequest(t, app, jf, "/studios?limit=1", jellyfintest.UserToken)
+	decodeBody(t, resp, &studios)
+	if len(studios) != 1 || studios[0].Name != "A24" {
+		t.Errorf("limit=1 returned %+v", studios)
+	}
+}
+
+func TestGetStudiosPaging(t *testing.T) {
+	setupTestEnv(t)
+	jf := jellyfintest.NewServer(t)
+	app := newStudiosTestApp()
+
+	items := make([]jellyfin.Item, 0, 650)
+	for i := range 650 {
+		studio := jellyfin.NameIDPair{ID: "studio-" + strconv.Itoa(i%2), Name: "Studio " + strconv.Itoa(i%2)}
+		items = append(items, jellyfin.Item{ID: strconv.Itoa(i), Type: "Movie", ParentID: jellyfintest.MoviesLibraryID, Studios: []jellyfin.NameIDPair{studio}})
+	}
+	jf.SetItems(items)
+
+	resp := doRequest(t, app, jf, "/studios", jellyfintest.AdminToken)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var studios []models.StudioSummary
+	decodeBody(t, resp, &studios)
+	if len(studios) != 2 || studios[0].Count != 325 || studios[1].Count != 325 {
+		t.Errorf("unexpected studios %+v", studios)
+	}
+
+	// Three pages from the movies library and one empty page from the shows
+	// library.
+	if got := jf.Requests("/Users/" + jellyfintest.AdminUserID + "/Items"); got != 4 {
+		t.Errorf("Jellyfin items requested %d times, want 4", got)
+	}
+}
+
+func TestGetStudiosJellyfinFailures(t *testing.T) {
+	setupTestEnv(t)
+	app := newStudiosTestApp()
+
+	t.Run("invalid token", func(t *testing.T) {
+		jf := jellyfintest.NewServer(t)
+
+		resp := doRequest(t, app, jf, "/studios", "not-a-token")
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadGateway)
+		}
+	})
+
+	t.Run("items unavailable", func(t *testing.T) {
+		jf := jellyfintest.NewServer(t)
+		jf.Fail("/Users/"+jellyfintest.UserUserID+"/Items", jellyfintest.Failure{Status: http.StatusInternalServerError})
+
+		resp := doRequest(t, app, jf, "/studios", jellyfintest.UserToken)
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadGateway)
+		}
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		jf := jellyfintest.NewServer(t)
+
+		resp := doRequest(t, app, jf, "/studios", "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
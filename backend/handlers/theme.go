@@ -33,7 +33,13 @@ func InitThemeStore() {
 }
 
 func GetThemes(c fiber.Ctx) error {
-	return c.Status(fiber.StatusOK).JSON(themeStore.GetAll())
+	data, err := json.Marshal(themeStore.GetAll())
+	if err != nil {
+		log.Println("Error encoding themes:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to load themes"})
+	}
+
+	return sendConditionalJSON(c, data, themeStore.ModTime(""))
 }
 
 func GetTheme(c fiber.Ctx) error {
@@ -48,7 +54,13 @@ func GetTheme(c fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(models.APIError{Error: "Theme not found"})
 	}
 
-	return c.Status(fiber.StatusOK).JSON(theme)
+	data, err := json.Marshal(theme)
+	if err != nil {
+		log.Println("Error encoding theme:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to load theme"})
+	}
+
+	return sendConditionalJSON(c, data, themeStore.ModTime(id))
 }
 
 func CreateTheme(c fiber.Ctx) error {
@@ -71,6 +83,8 @@ func CreateTheme(c fiber.Ctx) error {
 	}
 
 	log.Printf("Theme created: %s (ID: %s)", theme.Name, id)
+	eventBus.Publish(models.EventThemeCreated, fiber.Map{"id": id})
+	recordAudit(c, models.AuditEntry{Action: models.AuditThemeCreate, Target: id, Details: fiber.Map{"name": theme.Name}})
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"id": id})
 }
@@ -100,6 +114,8 @@ func UpdateTheme(c fiber.Ctx) error {
 	}
 
 	log.Printf("Theme updated: ID %s", id)
+	eventBus.Publish(models.EventThemeUpdated, fiber.Map{"id": id})
+	recordAudit(c, models.AuditEntry{Action: models.AuditThemeUpdate, Target: id, Details: fiber.Map{"name": theme.Name}})
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -179,6 +195,8 @@ func InstallTheme(c fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Invalid theme from repository: " + err.Error()})
 	}
 
+	_, existsErr := themeStore.Get(id)
+
 	newID, err := themeStore.Write(id, theme)
 	if err != nil {
 		log.Println("Error saving theme:", err)
@@ -186,6 +204,12 @@ func InstallTheme(c fiber.Ctx) error {
 	}
 
 	log.Printf("Theme installed: %s (ID: %s)", theme.Name, newID)
+	if existsErr == nil {
+		eventBus.Publish(models.EventThemeUpdated, fiber.Map{"id": newID})
+	} else {
+		eventBus.Publish(models.EventThemeCreated, fiber.Map{"id": newID})
+	}
+	recordAudit(c, models.AuditEntry{Action: models.AuditThemeInstall, Target: newID, Details: fiber.Map{"name": theme.Name}})
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -203,6 +227,8 @@ func DeleteTheme(c fiber.Ctx) error {
 	}
 
 	log.Printf("Theme deleted: ID %s", id)
+	eventBus.Publish(models.EventThemeDeleted, fiber.Map{"id": id})
+	recordAudit(c, models.AuditEntry{Action: models.AuditThemeDelete, Target: id})
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
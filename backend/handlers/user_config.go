@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"path/filepath"
+
+	"pelagica-backend/jellyfin"
+	"pelagica-backend/models"
+	"pelagica-backend/services"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const userOverlaysSubdir = "user-overlays"
+
+// alwaysLockedConfigPaths can never be overridden by a user overlay,
+// regardless of the admin's lockedFields setting.
+var alwaysLockedConfigPaths = []string{"/$schema", "/lockedFields"}
+
+var userOverlayStore *services.OverlayStore
+
+func userOverlaysDir() string {
+	return filepath.Join(filepath.Dir(configPath()), userOverlaysSubdir)
+}
+
+func InitUserOverlayStore() {
+	store, err := services.NewUserOverlayStore(userOverlaysDir())
+	if err != nil {
+		panic(err)
+	}
+	userOverlayStore = store
+}
+
+// errServerNotPinned is returned when user config is used while any
+// Jellyfin server could be named by the client, which would let a fake
+// server claim to be any user.
+var errServerNotPinned = errors.New("user config requires JELLYFIN_SERVER_URLS or a registered server")
+
+// resolveCallerOverlayKey returns the key of the user config of the caller:
+// the Jellyfin user belonging to the token of the current request, on the
+// server it belongs to. Tokens are only resolved against pinned servers.
+func resolveCallerOverlayKey(c fiber.Ctx) (string, error) {
+	if len(pinnedJellyfinURLs()) == 0 {
+		return "", errServerNotPinned
+	}
+
+	publicURL, err := resolveJellyfinURL(c)
+	if err != nil {
+		return "", err
+	}
+
+	jellyfinURL, token, err := parseJellyfinCredentials(c)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := jellyfin.ResolveUser(jellyfinURL, token)
+	if err != nil {
+		return "", err
+	}
+	if user.Id == "" {
+		return "", errors.New("failed to resolve Jellyfin user: empty user id")
+	}
+
+	return services.UserOverlayKey(serverIDForURL(publicURL), user.Id), nil
+}
+
+// lockedConfigPaths returns the paths of the global config that users may
+// not override.
+func lockedConfigPaths(global []byte) []string {
+	var cfg models.AppConfig
+	json.Unmarshal(global, &cfg)
+
+	return append(append([]string{}, alwaysLockedConfigPaths...), cfg.LockedFields...)
+}
+
+// applyCallerOverlay merges the caller's overlay into the global (or
+// server) config. If the caller cannot be identified or has no overlay,
+// global is returned.
+func applyCallerOverlay(c fiber.Ctx, global []byte) []byte {
+	if c.Get("Authorization") == "" || isGlobalScope(c) || len(pinnedJellyfinURLs()) == 0 {
+		return global
+	}
+
+	key, err := resolveCallerOverlayKey(c)
+	if err != nil {
+		log.Println("Could not resolve user for config overlay:", err)
+		return global
+	}
+
+	overlay, err := userOverlayStore.Get(key)
+	if err != nil {
+		log.Println("Error reading user config overlay:", err)
+		return global
+	}
+	if overlay == nil {
+		return global
+	}
+
+	merged, err := services.ApplyOverlay(global, overlay, lockedConfigPaths(global))
+	if err != nil {
+		log.Println("Error applying user config overlay:", err)
+		return global
+	}
+
+	return merged
+}
+
+// userResolveError answers a request whose user could not be resolved.
+func userResolveError(c fiber.Ctx, err error) error {
+	if errors.Is(err, errServerNotPinned) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "User config is only available when the Jellyfin server is pinned"})
+	}
+	log.Println("Error resolving user:", err)
+	return c.Status(fiber.StatusUnauthorized).JSON(models.APIError{Error: "Failed to identify Jellyfin user"})
+}
+
+func GetUserConfigOverlay(c fiber.Ctx) error {
+	key, err := resolveCallerOverlayKey(c)
+	if err != nil {
+		return userResolveError(c, err)
+	}
+
+	overlay, err := userOverlayStore.Get(key)
+	if err != nil {
+		log.Println("Error reading user config overlay:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read user config"})
+	}
+	if overlay == nil {
+		overlay = []byte(`{}`)
+	}
+
+	return c.Status(fiber.StatusOK).
+		Type("json").
+		Send(overlay)
+}
+
+func UpdateUserConfigOverlay(c fiber.Ctx) error {
+	key, err := resolveCallerOverlayKey(c)
+	if err != nil {
+		return userResolveError(c, err)
+	}
+
+	var overlay map[string]any
+	if err := json.Unmarshal(c.Body(), &overlay); err != nil || overlay == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "User config must be a JSON object"})
+	}
+
+	global, err := readConfigFile()
+	if err != nil {
+		log.Println("Error reading config file:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to read config file"})
+	}
+	global = applyServerConfig(c, global)
+
+	data, err := json.MarshalIndent(overlay, "", "    ")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid user config"})
+	}
+
+	touched, err := services.LockedOverlayPaths(data, lockedConfigPaths(global))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid user config"})
+	}
+	if len(touched) > 0 {
+		fieldErrors := make([]models.FieldError, 0, len(touched))
+		for _, path := range touched {
+			fieldErrors = append(fieldErrors, models.FieldError{Path: path, Message: "is locked by the administrator"})
+		}
+		return c.Status(fiber.StatusForbidden).JSON(models.APIError{Error: "User config changes locked fields", Fields: fieldErrors})
+	}
+
+	merged, err := services.ApplyMergePatch(global, data)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid user config"})
+	}
+
+	var cfg models.AppConfig
+	if err := json.Unmarshal(merged, &cfg); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid user config"})
+	}
+	if fieldErrors := cfg.Validate(); len(fieldErrors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.APIError{Error: "Invalid user config", Fields: fieldErrors})
+	}
+
+	if err := userOverlayStore.Write(key, data); err != nil {
+		log.Println("Error writing user config overlay:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to save user config"})
+	}
+	markConfigModified()
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func DeleteUserConfigOverlay(c fiber.Ctx) error {
+	key, err := resolveCallerOverlayKey(c)
+	if err != nil {
+		return userResolveError(c, err)
+	}
+
+	if err := userOverlayStore.Delete(key); err != nil {
+		log.Println("Error deleting user config overlay:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.APIError{Error: "Failed to reset user config"})
+	}
+	markConfigModified()
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
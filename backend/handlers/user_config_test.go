@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"pelagica-backend/jellyfin/jellyfintest"
+	"pelagica-backend/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestUserConfigRequiresPinnedServer(t *testing.T) {
+	setupTestEnv(t)
+	InitUserOverlayStore()
+	jf := jellyfintest.NewServer(t)
+
+	if err := userOverlayStore.Write(jellyfintest.UserUserID, []byte(`{"serverName": "Mine"}`)); err != nil {
+		t.Fatalf("writing user config: %v", err)
+	}
+
+	app := fiber.New()
+	app.Get("/config", GetConfig)
+	app.Get("/config/user", GetUserConfigOverlay)
+
+	if resp := doRequest(t, app, jf, "/config/user", jellyfintest.UserToken); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unpinned user config status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	var cfg models.AppConfig
+	decodeBody(t, doRequest(t, app, jf, "/config", jellyfintest.UserToken), &cfg)
+	if cfg.ServerName != "" {
+		t.Errorf("unpinned config server name = %q, want empty", cfg.ServerName)
+	}
+
+	t.Setenv("JELLYFIN_SERVER_URLS", jf.URL)
+
+	if resp := doRequest(t, app, jf, "/config/user", jellyfintest.UserToken); resp.StatusCode != http.StatusOK {
+		t.Errorf("pinned user config status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	cfg = models.AppConfig{}
+	decodeBody(t, doRequest(t, app, jf, "/config", jellyfintest.UserToken), &cfg)
+	if cfg.ServerName != "Mine" {
+		t.Errorf("pinned config server name = %q, want %q", cfg.ServerName, "Mine")
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"pelagica-backend/services"
+)
+
+const defaultWatchDebounce = 500 * time.Millisecond
+
+// StartFileWatcher reloads the config cache and the theme store whenever
+// their files are edited outside of the API, e.g. by git or Ansible.
+// It returns nil if watching is unavailable, in which case config is read
+// from disk on every request as before.
+func StartFileWatcher() *services.FileWatcher {
+	watcher, err := services.NewFileWatcher(positiveFromEnv("WATCH_DEBOUNCE", defaultWatchDebounce))
+	if err != nil {
+		log.Println("File watcher unavailable, hot reload disabled:", err)
+		return nil
+	}
+
+	if err := watcher.WatchFile(configPath(), ReloadConfig); err != nil {
+		log.Println("Failed to watch config file, config hot reload disabled:", err)
+	} else {
+		cachedConfig.mu.Lock()
+		cachedConfig.enabled = true
+		cachedConfig.mu.Unlock()
+		ReloadConfig()
+	}
+
+	if err := watcher.WatchDir(themeStore.Dir(), reloadThemes); err != nil {
+		log.Println("Failed to watch themes directory, theme hot reload disabled:", err)
+	}
+
+	if err := watcher.WatchFile(roleStore.Path(), reloadRoles); err != nil {
+		log.Println("Failed to watch roles file, roles hot reload disabled:", err)
+	}
+
+	if err := watcher.WatchFile(serverStore.Path(), reloadServers); err != nil {
+		log.Println("Failed to watch servers file, servers hot reload disabled:", err)
+	}
+
+	log.Println("Watching config and themes for changes")
+	return watcher
+}
+
+func reloadThemes() {
+	if err := themeStore.Reload(); err != nil {
+		log.Println("Error reloading themes:", err)
+		return
+	}
+	log.Println("Themes reloaded from disk")
+}
+
+func reloadRoles() {
+	if err := roleStore.Reload(); err != nil {
+		log.Println("Error reloading roles, keeping last good roles:", err)
+		return
+	}
+	log.Println("Roles reloaded from disk")
+}
@@ -0,0 +1,48 @@
+package jellyfin
+
+import "strings"
+
+// TokenFromAuthorization extracts the bare access token from an
+// Authorization header in MediaBrowser, Bearer or raw token form.
+func TokenFromAuthorization(header string) string {
+	header = strings.TrimSpace(header)
+
+	token := tokenFromMediaBrowserHeader(header)
+	if token == "" {
+		token = header
+	}
+
+	if strings.HasPrefix(strings.ToLower(token), "bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+
+	return token
+}
+
+func tokenFromMediaBrowserHeader(header string) string {
+	lower := strings.ToLower(header)
+	if !strings.HasPrefix(lower, "mediabrowser") {
+		return ""
+	}
+
+	parts := strings.Split(header, ",")
+	for _, part := range parts {
+		piece := strings.TrimSpace(part)
+		if !strings.Contains(strings.ToLower(piece), "token=") {
+			continue
+		}
+
+		idx := strings.Index(piece, "=")
+		if idx < 0 || idx+1 >= len(piece) {
+			continue
+		}
+
+		value := strings.TrimSpace(piece[idx+1:])
+		value = strings.Trim(value, `"`)
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
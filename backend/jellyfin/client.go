@@ -1,65 +1,196 @@
+// Package jellyfin is a small typed client for the parts of the Jellyfin
+// API the backend needs.
 package jellyfin
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
+)
 
-	"github.com/gofiber/fiber/v3"
+const (
+	defaultRequestTimeout = 30 * time.Second
+	defaultMaxRetries     = 2
+	defaultRetryBackoff   = 250 * time.Millisecond
+	maxErrorBodyBytes     = 1024
 )
 
-type UserMeResponse struct {
-	Id     string `json:"Id"`
-	Name   string `json:"Name"`
-	Policy struct {
-		IsAdministrator bool `json:"IsAdministrator"`
-	} `json:"Policy"`
+// sharedHTTPClient is reused by all clients so connections are pooled.
+var sharedHTTPClient = &http.Client{Timeout: defaultRequestTimeout}
+
+// Client talks to one Jellyfin server on behalf of one access token.
+type Client struct {
+	baseURL      *url.URL
+	token        string
+	httpClient   *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
 }
 
-func AuthenticateByToken(c fiber.Ctx) (bool, error) {
-	jellyfinURLRaw := c.Query("jellyfin_url")
-	if jellyfinURLRaw == "" {
-		return false, errors.New("missing jellyfin_url query parameter")
+type Option func(*Client)
+
+// WithHTTPClient replaces the shared HTTP client, e.g. to change timeouts.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
 	}
+}
 
-	baseURL, err := url.Parse(jellyfinURLRaw)
-	if err != nil {
-		return false, errors.New("invalid jellyfin_url")
+// WithTimeout uses a dedicated HTTP client with the given timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient = &http.Client{Timeout: timeout}
 	}
+}
 
-	endpoint, _ := url.Parse("/Users/Me")
-	fullURL := baseURL.ResolveReference(endpoint)
+// WithRetries sets how often failed GET requests are retried and the base
+// delay between attempts.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryBackoff = backoff
+	}
+}
 
-	token := c.Get("Authorization")
+func NewClient(baseURL, token string, opts ...Option) (*Client, error) {
+	parsed, err := url.ParseRequestURI(strings.TrimSpace(baseURL))
+	if err != nil || parsed.Host == "" {
+		return nil, errors.New("invalid Jellyfin URL")
+	}
 	if token == "" {
-		return false, errors.New("missing Authorization header")
+		return nil, errors.New("missing Jellyfin token")
 	}
 
-	req, err := http.NewRequest(http.MethodGet, fullURL.String(), nil)
-	if err != nil {
-		return false, err
+	c := &Client{
+		baseURL:      parsed,
+		token:        token,
+		httpClient:   sharedHTTPClient,
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 
-	req.Header.Set("X-Emby-Token", token)
+	return c, nil
+}
 
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return false, err
+// BaseURL returns the server URL the client was created with.
+func (c *Client) BaseURL() string {
+	return c.baseURL.String()
+}
+
+func (c *Client) endpoint(path string, query url.Values) string {
+	endpoint, _ := url.Parse(path)
+	fullURL := c.baseURL.ResolveReference(endpoint)
+	if len(query) > 0 {
+		fullURL.RawQuery = query.Encode()
 	}
-	defer res.Body.Close()
+	return fullURL.String()
+}
 
-	if res.StatusCode != http.StatusOK {
-		if res.StatusCode == http.StatusUnauthorized {
-			return false, errors.New("unauthorized: invalid Jellyfin token")
+func (c *Client) applyAuthHeaders(req *http.Request) {
+	req.Header.Set("X-Emby-Token", c.token)
+	req.Header.Set("Authorization", `MediaBrowser Token="`+c.token+`"`)
+}
+
+// get performs a GET request and returns the response for a 200 status.
+// Network errors and 5xx responses are retried; the caller must close the
+// body.
+func (c *Client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	fullURL := c.endpoint(path, query)
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(c.retryBackoff * time.Duration(attempt)):
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.applyAuthHeaders(req)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		lastErr = newError(req, resp)
+		resp.Body.Close()
+
+		if resp.StatusCode < http.StatusInternalServerError {
+			return nil, lastErr
 		}
-		return false, errors.New("failed to authenticate with Jellyfin: " + res.Status)
 	}
 
-	var user UserMeResponse
-	if err := json.NewDecoder(res.Body).Decode(&user); err != nil {
-		return false, err
+	return nil, lastErr
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, out any) error {
+	resp, err := c.get(ctx, path, query)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Error is returned when Jellyfin answers with a non-200 status.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func newError(req *http.Request, resp *http.Response) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	return &Error{
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       strings.TrimSpace(string(body)),
+	}
+}
+
+func (e *Error) Error() string {
+	msg := "jellyfin: " + e.Method + " " + e.Path + " returned " + e.Status
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// IsUnauthorized reports whether err is a Jellyfin 401 response.
+func IsUnauthorized(err error) bool {
+	var jfErr *Error
+	return errors.As(err, &jfErr) && jfErr.StatusCode == http.StatusUnauthorized
+}
 
-	return user.Policy.IsAdministrator, nil
+// IsNotFound reports whether err is a Jellyfin 404 response.
+func IsNotFound(err error) bool {
+	var jfErr *Error
+	return errors.As(err, &jfErr) && jfErr.StatusCode == http.StatusNotFound
 }
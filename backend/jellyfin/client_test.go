@@ -0,0 +1,126 @@
+package jellyfin_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"pelagica-backend/jellyfin"
+	"pelagica-backend/jellyfin/jellyfintest"
+)
+
+func TestCurrentUser(t *testing.T) {
+	server := jellyfintest.NewServer(t)
+
+	client, err := jellyfin.NewClient(server.URL, jellyfintest.AdminToken)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	user, err := client.CurrentUser(context.Background())
+	if err != nil {
+		t.Fatalf("CurrentUser: %v", err)
+	}
+	if user.Id != jellyfintest.AdminUserID || user.Name != "admin" || !user.Policy.IsAdministrator {
+		t.Errorf("unexpected user %+v", user)
+	}
+}
+
+func TestCurrentUserUnauthorized(t *testing.T) {
+	server := jellyfintest.NewServer(t)
+
+	client, err := jellyfin.NewClient(server.URL, "wrong")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	_, err = client.CurrentUser(context.Background())
+	if !jellyfin.IsUnauthorized(err) {
+		t.Fatalf("expected unauthorized error, got %v", err)
+	}
+
+	jfErr := err.(*jellyfin.Error)
+	if jfErr.Path != "/Users/Me" || jfErr.Body != "Access token is invalid or expired." {
+		t.Errorf("unexpected error details %+v", jfErr)
+	}
+}
+
+func TestEachItemsPage(t *testing.T) {
+	server := jellyfintest.NewServer(t)
+
+	items := make([]jellyfin.Item, 7)
+	for i := range items {
+		items[i] = jellyfin.Item{ID: strconv.Itoa(i), Name: "Item " + strconv.Itoa(i), Type: "Movie"}
+	}
+	server.SetItems(items)
+
+	client, err := jellyfin.NewClient(server.URL, jellyfintest.UserToken)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	var pages, count int
+	err = client.EachItemsPage(context.Background(), jellyfintest.UserUserID, jellyfin.NewItemsQuery().Recursive(), 3, func(page []jellyfin.Item) error {
+		pages++
+		count += len(page)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachItemsPage: %v", err)
+	}
+	if pages != 3 || count != 7 {
+		t.Errorf("got %d pages with %d items, want 3 pages with 7 items", pages, count)
+	}
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	server := jellyfintest.NewServer(t)
+	server.Fail("/Users/Me", jellyfintest.Failure{Status: http.StatusServiceUnavailable, Count: 2})
+
+	client, err := jellyfin.NewClient(server.URL, jellyfintest.UserToken, jellyfin.WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	if _, err := client.CurrentUser(context.Background()); err != nil {
+		t.Fatalf("CurrentUser: %v", err)
+	}
+	if calls := server.Requests("/Users/Me"); calls != 3 {
+		t.Errorf("server called %d times, want 3", calls)
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	server := jellyfintest.NewServer(t)
+	server.Fail("/Users/", jellyfintest.Failure{Status: http.StatusNotFound})
+
+	client, err := jellyfin.NewClient(server.URL, jellyfintest.UserToken, jellyfin.WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	path := "/Users/" + jellyfintest.UserUserID + "/Views"
+	if _, err := client.Views(context.Background(), jellyfintest.UserUserID); !jellyfin.IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if calls := server.Requests(path); calls != 1 {
+		t.Errorf("server called %d times, want 1", calls)
+	}
+}
+
+func TestTokenFromAuthorization(t *testing.T) {
+	tests := map[string]string{
+		"abc":                                    "abc",
+		"Bearer abc":                             "abc",
+		`MediaBrowser Client="web", Token="abc"`: "abc",
+		`MediaBrowser Token=abc`:                 "abc",
+	}
+
+	for header, want := range tests {
+		if got := jellyfin.TokenFromAuthorization(header); got != want {
+			t.Errorf("TokenFromAuthorization(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package jellyfin
+
+import (
+	"context"
+	"io"
+	"net/url"
+)
+
+const maxImageBytes = 20 * 1024 * 1024
+
+// ImageURL returns the URL of an item image, e.g. imageType "Primary".
+func (c *Client) ImageURL(itemID, imageType string, query url.Values) string {
+	return c.endpoint("/Items/"+url.PathEscape(itemID)+"/Images/"+url.PathEscape(imageType), query)
+}
+
+// Image downloads an item image and returns its bytes and content type.
+func (c *Client) Image(ctx context.Context, itemID, imageType string, query url.Values) ([]byte, string, error) {
+	resp, err := c.get(ctx, "/Items/"+url.PathEscape(itemID)+"/Images/"+url.PathEscape(imageType), query)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, resp.Header.Get("Content-Type"), nil
+}
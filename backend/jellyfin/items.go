@@ -0,0 +1,171 @@
+package jellyfin
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const DefaultPageSize = 300
+
+type NameIDPair struct {
+	ID   string `json:"Id"`
+	Name string `json:"Name"`
+}
+
+type Person struct {
+	ID   string `json:"Id"`
+	Name string `json:"Name"`
+	Role string `json:"Role,omitempty"`
+	Type string `json:"Type"`
+}
+
+type Item struct {
+	ID             string            `json:"Id"`
+	Name           string            `json:"Name"`
+	Type           string            `json:"Type"`
+	ParentID       string            `json:"ParentId,omitempty"`
+	CollectionType string            `json:"CollectionType,omitempty"`
+	ProductionYear int               `json:"ProductionYear,omitempty"`
+	OfficialRating string            `json:"OfficialRating,omitempty"`
+	DateCreated    string            `json:"DateCreated,omitempty"`
+	Genres         []string          `json:"Genres,omitempty"`
+	GenreItems     []NameIDPair      `json:"GenreItems,omitempty"`
+	Tags           []string          `json:"Tags,omitempty"`
+	Studios        []NameIDPair      `json:"Studios,omitempty"`
+	People         []Person          `json:"People,omitempty"`
+	ImageTags      map[string]string `json:"ImageTags,omitempty"`
+}
+
+type ItemsPage struct {
+	Items            []Item `json:"Items"`
+	TotalRecordCount int    `json:"TotalRecordCount"`
+	StartIndex       int    `json:"StartIndex"`
+}
+
+// ItemsQuery builds the query string for item listing endpoints. The zero
+// value lists everything Jellyfin returns by default.
+type ItemsQuery struct {
+	values url.Values
+}
+
+func NewItemsQuery() *ItemsQuery {
+	return &ItemsQuery{values: url.Values{}}
+}
+
+func (q *ItemsQuery) set(key, value string) *ItemsQuery {
+	if q.values == nil {
+		q.values = url.Values{}
+	}
+	q.values.Set(key, value)
+	return q
+}
+
+func (q *ItemsQuery) Recursive() *ItemsQuery {
+	return q.set("Recursive", "true")
+}
+
+func (q *ItemsQuery) IncludeItemTypes(types ...string) *ItemsQuery {
+	return q.set("IncludeItemTypes", strings.Join(types, ","))
+}
+
+func (q *ItemsQuery) Fields(fields ...string) *ItemsQuery {
+	return q.set("Fields", strings.Join(fields, ","))
+}
+
+func (q *ItemsQuery) ParentID(id string) *ItemsQuery {
+	return q.set("ParentId", id)
+}
+
+func (q *ItemsQuery) SortBy(fields ...string) *ItemsQuery {
+	return q.set("SortBy", strings.Join(fields, ","))
+}
+
+func (q *ItemsQuery) EnableImages(enabled bool) *ItemsQuery {
+	return q.set("EnableImages", strconv.FormatBool(enabled))
+}
+
+func (q *ItemsQuery) MinDateLastSaved(value string) *ItemsQuery {
+	return q.set("MinDateLastSaved", value)
+}
+
+func (q *ItemsQuery) UserID(id string) *ItemsQuery {
+	return q.set("UserId", id)
+}
+
+// Set adds any other query parameter supported by the endpoint.
+func (q *ItemsQuery) Set(key, value string) *ItemsQuery {
+	return q.set(key, value)
+}
+
+func (q *ItemsQuery) page(startIndex, limit int) url.Values {
+	values := url.Values{}
+	for key, v := range q.values {
+		values[key] = append([]string(nil), v...)
+	}
+	values.Set("StartIndex", strconv.Itoa(startIndex))
+	values.Set("Limit", strconv.Itoa(limit))
+	return values
+}
+
+// Items returns a single page of items visible to userID.
+func (c *Client) Items(ctx context.Context, userID string, q *ItemsQuery, startIndex, limit int) (ItemsPage, error) {
+	return c.itemsPage(ctx, "/Users/"+url.PathEscape(userID)+"/Items", q, startIndex, limit)
+}
+
+// EachItemsPage pages through every item matching q, calling fn once per
+// page until all items were seen or fn returns an error.
+func (c *Client) EachItemsPage(ctx context.Context, userID string, q *ItemsQuery, pageSize int, fn func([]Item) error) error {
+	return c.eachPage(ctx, "/Users/"+url.PathEscape(userID)+"/Items", q, pageSize, fn)
+}
+
+// Genres returns a page of genres; use q.UserID and q.ParentID to scope them.
+func (c *Client) Genres(ctx context.Context, q *ItemsQuery, startIndex, limit int) (ItemsPage, error) {
+	return c.itemsPage(ctx, "/Genres", q, startIndex, limit)
+}
+
+// Persons returns a page of people; use q.Set("PersonTypes", ...) to filter
+// by role.
+func (c *Client) Persons(ctx context.Context, q *ItemsQuery, startIndex, limit int) (ItemsPage, error) {
+	return c.itemsPage(ctx, "/Persons", q, startIndex, limit)
+}
+
+func (c *Client) itemsPage(ctx context.Context, path string, q *ItemsQuery, startIndex, limit int) (ItemsPage, error) {
+	if q == nil {
+		q = NewItemsQuery()
+	}
+
+	var page ItemsPage
+	if err := c.getJSON(ctx, path, q.page(startIndex, limit), &page); err != nil {
+		return ItemsPage{}, err
+	}
+	return page, nil
+}
+
+func (c *Client) eachPage(ctx context.Context, path string, q *ItemsQuery, pageSize int, fn func([]Item) error) error {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	startIndex := 0
+	for {
+		page, err := c.itemsPage(ctx, path, q, startIndex, pageSize)
+		if err != nil {
+			return err
+		}
+
+		if len(page.Items) == 0 {
+			return nil
+		}
+
+		if err := fn(page.Items); err != nil {
+			return err
+		}
+
+		startIndex += len(page.Items)
+		if len(page.Items) < pageSize {
+			return nil
+		}
+	}
+}
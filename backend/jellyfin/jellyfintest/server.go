@@ -0,0 +1,433 @@
+// Package jellyfintest provides a fake Jellyfin server for tests. It serves
+// the subset of the API used by the backend from seeded, in-memory data and
+// can inject failures and delays per endpoint.
+package jellyfintest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pelagica-backend/jellyfin"
+)
+
+const (
+	AdminToken  = "admin-token"
+	UserToken   = "user-token"
+	AdminUserID = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	UserUserID  = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+
+	MoviesLibraryID = "11111111111111111111111111111111"
+	ShowsLibraryID  = "22222222222222222222222222222222"
+)
+
+// Failure describes how requests to a path should misbehave. Status 0 only
+// applies Delay; Count 0 applies the failure to every request.
+type Failure struct {
+	Status int
+	Delay  time.Duration
+	Count  int
+}
+
+type failureRule struct {
+	prefix  string
+	failure Failure
+	used    int
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	users    map[string]jellyfin.User
+	views    []jellyfin.Item
+	items    []jellyfin.Item
+	savedAt  map[string]time.Time
+	failures []*failureRule
+	requests map[string]int
+}
+
+// NewServer starts a fake Jellyfin seeded with an admin and a regular user,
+// a movie and a show library and a handful of items. It is closed when the
+// test finishes.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{
+		users:    map[string]jellyfin.User{},
+		savedAt:  map[string]time.Time{},
+		requests: map[string]int{},
+	}
+	s.seed()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /Users/Me", s.handleMe)
+	mux.HandleFunc("GET /Users/{id}/Items", s.handleItems)
+	mux.HandleFunc("GET /Users/{id}/Views", s.handleViews)
+	mux.HandleFunc("GET /Genres", s.handleGenres)
+	mux.HandleFunc("GET /Persons", s.handlePersons)
+	mux.HandleFunc("GET /Items/{id}/Images/{type}", s.handleImage)
+
+	s.Server = httptest.NewServer(s.middleware(mux))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *Server) seed() {
+	admin := jellyfin.User{Id: AdminUserID, Name: "admin"}
+	admin.Policy.IsAdministrator = true
+	s.users[AdminToken] = admin
+	s.users[UserToken] = jellyfin.User{Id: UserUserID, Name: "user"}
+
+	s.views = []jellyfin.Item{
+		{ID: MoviesLibraryID, Name: "Movies", Type: "CollectionFolder", CollectionType: "movies"},
+		{ID: ShowsLibraryID, Name: "Shows", Type: "CollectionFolder", CollectionType: "tvshows"},
+	}
+
+	a24 := jellyfin.NameIDPair{ID: "studio-a24", Name: "A24"}
+	ghibli := jellyfin.NameIDPair{ID: "studio-ghibli", Name: "Studio Ghibli"}
+	hbo := jellyfin.NameIDPair{ID: "studio-hbo", Name: "HBO"}
+
+	s.setItemsLocked([]jellyfin.Item{
+		{ID: "movie-1", Name: "Spirited Away", Type: "Movie", ParentID: MoviesLibraryID, ProductionYear: 2001, OfficialRating: "PG",
+			Genres: []string{"Animation", "Fantasy"}, Tags: []string{"anime"}, Studios: []jellyfin.NameIDPair{ghibli},
+			People: []jellyfin.Person{{ID: "person-1", Name: "Hayao Miyazaki", Type: "Director"}}},
+		{ID: "movie-2", Name: "My Neighbor Totoro", Type: "Movie", ParentID: MoviesLibraryID, ProductionYear: 1988, OfficialRating: "G",
+			Genres: []string{"Animation", "Family"}, Tags: []string{"anime"}, Studios: []jellyfin.NameIDPair{ghibli},
+			People: []jellyfin.Person{{ID: "person-1", Name: "Hayao Miyazaki", Type: "Director"}}},
+		{ID: "movie-3", Name: "Moonlight", Type: "Movie", ParentID: MoviesLibraryID, ProductionYear: 2016, OfficialRating: "R",
+			Genres: []string{"Drama"}, Studios: []jellyfin.NameIDPair{a24},
+			People: []jellyfin.Person{{ID: "person-2", Name: "Mahershala Ali", Role: "Juan", Type: "Actor"}}},
+		{ID: "movie-4", Name: "Lady Bird", Type: "Movie", ParentID: MoviesLibraryID, ProductionYear: 2017, OfficialRating: "R",
+			Genres: []string{"Comedy", "Drama"}, Tags: []string{"coming-of-age"}, Studios: []jellyfin.NameIDPair{a24}},
+		{ID: "series-1", Name: "Euphoria", Type: "Series", ParentID: ShowsLibraryID, ProductionYear: 2019, OfficialRating: "TV-MA",
+			Genres: []string{"Drama"}, Studios: []jellyfin.NameIDPair{a24, hbo}},
+		{ID: "series-2", Name: "Chernobyl", Type: "Series", ParentID: ShowsLibraryID, ProductionYear: 2019, OfficialRating: "TV-MA",
+			Genres: []string{"Drama", "History"}, Tags: []string{"miniseries"}, Studios: []jellyfin.NameIDPair{hbo}},
+	})
+}
+
+func (s *Server) setItemsLocked(items []jellyfin.Item) {
+	s.items = slices.Clone(items)
+	s.savedAt = map[string]time.Time{}
+	now := time.Now()
+	for _, item := range items {
+		s.savedAt[item.ID] = now
+	}
+}
+
+// AddUser makes token authenticate as user.
+func (s *Server) AddUser(token string, user jellyfin.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[token] = user
+}
+
+// SetItems replaces all seeded items.
+func (s *Server) SetItems(items []jellyfin.Item) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setItemsLocked(items)
+}
+
+// AddItems appends items to the library.
+func (s *Server) AddItems(items ...jellyfin.Item) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, item := range items {
+		s.items = append(s.items, item)
+		s.savedAt[item.ID] = now
+	}
+}
+
+// UpdateItem replaces the item with the same ID and marks it as saved now,
+// so it is returned to MinDateLastSaved queries.
+func (s *Server) UpdateItem(item jellyfin.Item) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.items {
+		if s.items[i].ID == item.ID {
+			s.items[i] = item
+			s.savedAt[item.ID] = time.Now()
+		}
+	}
+}
+
+// SetSavedAt backdates when an item was last saved.
+func (s *Server) SetSavedAt(id string, savedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.savedAt[id] = savedAt
+}
+
+// Fail makes requests whose path starts with pathPrefix misbehave as
+// described by failure. Rules are checked in the order they were added.
+func (s *Server) Fail(pathPrefix string, failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failureRule{prefix: pathPrefix, failure: failure})
+}
+
+// ClearFailures removes all injected failures.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = nil
+}
+
+// Requests returns how many requests were made to path.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+func (s *Server) matchFailure(path string) (Failure, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rule := range s.failures {
+		if !strings.HasPrefix(path, rule.prefix) {
+			continue
+		}
+		if rule.failure.Count > 0 && rule.used >= rule.failure.Count {
+			continue
+		}
+		rule.used++
+		return rule.failure, true
+	}
+
+	return Failure{}, false
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		s.mu.Unlock()
+
+		if failure, ok := s.matchFailure(r.URL.Path); ok {
+			if failure.Delay > 0 {
+				select {
+				case <-time.After(failure.Delay):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			if failure.Status != 0 {
+				http.Error(w, http.StatusText(failure.Status), failure.Status)
+				return
+			}
+		}
+
+		if _, ok := s.userForRequest(r); !ok {
+			http.Error(w, "Access token is invalid or expired.", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) userForRequest(r *http.Request) (jellyfin.User, bool) {
+	token := r.Header.Get("X-Emby-Token")
+	if token == "" {
+		token = jellyfin.TokenFromAuthorization(r.Header.Get("Authorization"))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[token]
+	return user, ok
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	user, _ := s.userForRequest(r)
+	writeJSON(w, user)
+}
+
+// checkUserPath rejects requests for another user's data, like Jellyfin
+// does for non-administrators.
+func (s *Server) checkUserPath(w http.ResponseWriter, r *http.Request) bool {
+	user, _ := s.userForRequest(r)
+	if r.PathValue("id") == user.Id || user.Policy.IsAdministrator {
+		return true
+	}
+	http.Error(w, "Forbidden", http.StatusForbidden)
+	return false
+}
+
+func (s *Server) handleViews(w http.ResponseWriter, r *http.Request) {
+	if !s.checkUserPath(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	views := slices.Clone(s.views)
+	s.mu.Unlock()
+
+	writeJSON(w, jellyfin.ItemsPage{Items: views, TotalRecordCount: len(views)})
+}
+
+func (s *Server) handleItems(w http.ResponseWriter, r *http.Request) {
+	if !s.checkUserPath(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	types := splitList(query.Get("IncludeItemTypes"))
+	parentID := query.Get("ParentId")
+
+	var minSaved time.Time
+	if raw := query.Get("MinDateLastSaved"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "invalid MinDateLastSaved", http.StatusBadRequest)
+			return
+		}
+		minSaved = parsed
+	}
+
+	s.mu.Lock()
+	matched := []jellyfin.Item{}
+	for _, item := range s.items {
+		if len(types) > 0 && !slices.Contains(types, item.Type) {
+			continue
+		}
+		if parentID != "" && item.ParentID != parentID {
+			continue
+		}
+		if !minSaved.IsZero() && s.savedAt[item.ID].Before(minSaved) {
+			continue
+		}
+		matched = append(matched, item)
+	}
+	s.mu.Unlock()
+
+	writePage(w, r, matched)
+}
+
+func (s *Server) handleGenres(w http.ResponseWriter, r *http.Request) {
+	s.writeNames(w, r, func(item jellyfin.Item) []jellyfin.NameIDPair {
+		pairs := make([]jellyfin.NameIDPair, 0, len(item.Genres))
+		for _, genre := range item.Genres {
+			pairs = append(pairs, jellyfin.NameIDPair{ID: "genre-" + strings.ToLower(genre), Name: genre})
+		}
+		return pairs
+	}, "Genre")
+}
+
+func (s *Server) handlePersons(w http.ResponseWriter, r *http.Request) {
+	personTypes := splitList(r.URL.Query().Get("PersonTypes"))
+	s.writeNames(w, r, func(item jellyfin.Item) []jellyfin.NameIDPair {
+		pairs := []jellyfin.NameIDPair{}
+		for _, person := range item.People {
+			if len(personTypes) == 0 || slices.Contains(personTypes, person.Type) {
+				pairs = append(pairs, jellyfin.NameIDPair{ID: person.ID, Name: person.Name})
+			}
+		}
+		return pairs
+	}, "Person")
+}
+
+// writeNames lists the distinct names extracted from the items matching
+// ParentId, sorted by name and paged like Jellyfin does.
+func (s *Server) writeNames(w http.ResponseWriter, r *http.Request, extract func(jellyfin.Item) []jellyfin.NameIDPair, itemType string) {
+	parentID := r.URL.Query().Get("ParentId")
+
+	s.mu.Lock()
+	seen := map[string]bool{}
+	result := []jellyfin.Item{}
+	for _, item := range s.items {
+		if parentID != "" && item.ParentID != parentID {
+			continue
+		}
+		for _, pair := range extract(item) {
+			if seen[pair.ID] {
+				continue
+			}
+			seen[pair.ID] = true
+			result = append(result, jellyfin.Item{ID: pair.ID, Name: pair.Name, Type: itemType})
+		}
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(result, func(a, b jellyfin.Item) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	writePage(w, r, result)
+}
+
+// onePixelPNG is a valid 1x1 transparent PNG.
+var onePixelPNG = []byte{
+	0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d,
+	0x49, 0x48, 0x44, 0x52, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+	0x08, 0x06, 0x00, 0x00, 0x00, 0x1f, 0x15, 0xc4, 0x89, 0x00, 0x00, 0x00,
+	0x0d, 0x49, 0x44, 0x41, 0x54, 0x78, 0x9c, 0x63, 0x00, 0x01, 0x00, 0x00,
+	0x05, 0x00, 0x01, 0x0d, 0x0a, 0x2d, 0xb4, 0x00, 0x00, 0x00, 0x00, 0x49,
+	0x45, 0x4e, 0x44, 0xae, 0x42, 0x60, 0x82,
+}
+
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	found := slices.ContainsFunc(s.items, func(item jellyfin.Item) bool { return item.ID == id })
+	s.mu.Unlock()
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(onePixelPNG)
+}
+
+// writePage applies StartIndex and Limit the way Jellyfin does: a missing
+// Limit returns everything from StartIndex on.
+func writePage(w http.ResponseWriter, r *http.Request, items []jellyfin.Item) {
+	query := r.URL.Query()
+
+	start, _ := strconv.Atoi(query.Get("StartIndex"))
+	start = min(max(start, 0), len(items))
+
+	end := len(items)
+	if limit, err := strconv.Atoi(query.Get("Limit")); err == nil && limit >= 0 {
+		end = min(start+limit, len(items))
+	}
+
+	writeJSON(w, jellyfin.ItemsPage{
+		Items:            items[start:end],
+		TotalRecordCount: len(items),
+		StartIndex:       start,
+	})
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	parts := []string{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
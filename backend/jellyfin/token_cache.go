@@ -0,0 +1,188 @@
+package jellyfin
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultTokenCacheTTL         = time.Minute
+	defaultTokenCacheNegativeTTL = 10 * time.Second
+	defaultTokenCacheMaxEntries  = 1000
+	userRequestTimeout           = 10 * time.Second
+)
+
+type tokenCacheKey struct {
+	baseURL string
+	token   string
+}
+
+type tokenCacheEntry struct {
+	user      User
+	err       error
+	expiresAt time.Time
+}
+
+// TokenCacheStats reports how effective the token validation cache is.
+type TokenCacheStats struct {
+	Hits         uint64 `json:"hits"`
+	NegativeHits uint64 `json:"negativeHits"`
+	Misses       uint64 `json:"misses"`
+	Entries      int    `json:"entries"`
+	MaxEntries   int    `json:"maxEntries"`
+}
+
+var tokenCache = struct {
+	mu      sync.Mutex
+	entries map[tokenCacheKey]tokenCacheEntry
+}{
+	entries: map[tokenCacheKey]tokenCacheEntry{},
+}
+
+var (
+	tokenCacheHits         atomic.Uint64
+	tokenCacheNegativeHits atomic.Uint64
+	tokenCacheMisses       atomic.Uint64
+)
+
+// tokenCheckGroup deduplicates concurrent validations of the same token.
+var tokenCheckGroup singleflight.Group
+
+// TokenCacheConfig controls how long token validations are cached and how
+// many are kept. A zero TTL disables caching of that kind of result.
+type TokenCacheConfig struct {
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	MaxEntries  int
+}
+
+// DefaultTokenCacheConfig is used until ConfigureTokenCache is called.
+var DefaultTokenCacheConfig = TokenCacheConfig{
+	TTL:         defaultTokenCacheTTL,
+	NegativeTTL: defaultTokenCacheNegativeTTL,
+	MaxEntries:  defaultTokenCacheMaxEntries,
+}
+
+var tokenCacheConfig atomic.Pointer[TokenCacheConfig]
+
+// ConfigureTokenCache replaces the token cache settings. Entries already
+// cached keep their expiry.
+func ConfigureTokenCache(cfg TokenCacheConfig) {
+	tokenCacheConfig.Store(&cfg)
+}
+
+func currentTokenCacheConfig() TokenCacheConfig {
+	if cfg := tokenCacheConfig.Load(); cfg != nil {
+		return *cfg
+	}
+	return DefaultTokenCacheConfig
+}
+
+// ResolveUser returns the Jellyfin user owning token on the server at
+// baseURL. Successful lookups are cached for the configured TTL and
+// failures for the NegativeTTL.
+func ResolveUser(baseURL, token string) (User, error) {
+	key := tokenCacheKey{baseURL: baseURL, token: token}
+	now := time.Now()
+
+	tokenCache.mu.Lock()
+	entry, ok := tokenCache.entries[key]
+	tokenCache.mu.Unlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		if entry.err != nil {
+			tokenCacheNegativeHits.Add(1)
+		} else {
+			tokenCacheHits.Add(1)
+		}
+		return entry.user, entry.err
+	}
+
+	tokenCacheMisses.Add(1)
+
+	result, _, _ := tokenCheckGroup.Do(baseURL+"\n"+token, func() (interface{}, error) {
+		user, err := fetchCurrentUser(baseURL, token)
+
+		cfg := currentTokenCacheConfig()
+		ttl := cfg.TTL
+		if err != nil {
+			ttl = cfg.NegativeTTL
+		}
+
+		entry := tokenCacheEntry{user: user, err: err, expiresAt: time.Now().Add(ttl)}
+		if ttl > 0 {
+			storeTokenCacheEntry(key, entry)
+		}
+		return entry, nil
+	})
+
+	entry = result.(tokenCacheEntry)
+	return entry.user, entry.err
+}
+
+func fetchCurrentUser(baseURL, token string) (User, error) {
+	client, err := NewClient(baseURL, token, WithTimeout(userRequestTimeout))
+	if err != nil {
+		return User{}, err
+	}
+	return client.CurrentUser(context.Background())
+}
+
+func storeTokenCacheEntry(key tokenCacheKey, entry tokenCacheEntry) {
+	tokenCache.mu.Lock()
+	defer tokenCache.mu.Unlock()
+
+	maxEntries := currentTokenCacheConfig().MaxEntries
+	if _, exists := tokenCache.entries[key]; !exists && len(tokenCache.entries) >= maxEntries {
+		now := time.Now()
+		for k, e := range tokenCache.entries {
+			if !now.Before(e.expiresAt) {
+				delete(tokenCache.entries, k)
+			}
+		}
+
+		// Still full: evict the entry closest to expiring.
+		if len(tokenCache.entries) >= maxEntries {
+			var oldestKey tokenCacheKey
+			var oldest time.Time
+			for k, e := range tokenCache.entries {
+				if oldest.IsZero() || e.expiresAt.Before(oldest) {
+					oldestKey, oldest = k, e.expiresAt
+				}
+			}
+			delete(tokenCache.entries, oldestKey)
+		}
+	}
+
+	tokenCache.entries[key] = entry
+}
+
+// InvalidateToken drops all cached validations of token, e.g. on logout.
+func InvalidateToken(token string) {
+	tokenCache.mu.Lock()
+	defer tokenCache.mu.Unlock()
+
+	for key := range tokenCache.entries {
+		if key.token == token {
+			delete(tokenCache.entries, key)
+		}
+	}
+}
+
+func TokenCacheStatistics() TokenCacheStats {
+	tokenCache.mu.Lock()
+	entries := len(tokenCache.entries)
+	tokenCache.mu.Unlock()
+
+	return TokenCacheStats{
+		Hits:         tokenCacheHits.Load(),
+		NegativeHits: tokenCacheNegativeHits.Load(),
+		Misses:       tokenCacheMisses.Load(),
+		Entries:      entries,
+		MaxEntries:   currentTokenCacheConfig().MaxEntries,
+	}
+}
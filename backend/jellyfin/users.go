@@ -0,0 +1,39 @@
+package jellyfin
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+type User struct {
+	Id     string `json:"Id"`
+	Name   string `json:"Name"`
+	Policy struct {
+		IsAdministrator bool `json:"IsAdministrator"`
+	} `json:"Policy"`
+}
+
+// CurrentUser returns the user owning the client's token.
+func (c *Client) CurrentUser(ctx context.Context) (User, error) {
+	var user User
+	if err := c.getJSON(ctx, "/Users/Me", nil, &user); err != nil {
+		return User{}, err
+	}
+
+	if strings.TrimSpace(user.Id) == "" {
+		return User{}, errors.New("jellyfin: empty user id")
+	}
+
+	return user, nil
+}
+
+// Views returns the library views visible to userID.
+func (c *Client) Views(ctx context.Context, userID string) ([]Item, error) {
+	var page ItemsPage
+	if err := c.getJSON(ctx, "/Users/"+url.PathEscape(userID)+"/Views", nil, &page); err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
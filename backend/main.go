@@ -6,11 +6,14 @@ import (
 	"pelagica-backend/appconfig"
 	"pelagica-backend/collector"
 	"pelagica-backend/handlers"
+	"pelagica-backend/models"
 	"strings"
 
 	"github.com/gofiber/fiber/v3"
 )
 
+const maxRequestBodySizeBytes = 50 * 1024 * 1024
+
 func getPort() string {
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -25,40 +28,112 @@ func isAuthEnabled() bool {
 }
 
 func main() {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		BodyLimit: maxRequestBodySizeBytes,
+	})
 	appconfig.Setup(app)
 
+	handlers.InitTokenCache()
 	handlers.InitThemeStore()
+	handlers.InitConfigHistory()
+	handlers.InitUserOverlayStore()
+	handlers.InitRoleStore()
+	handlers.InitServerStore()
+	handlers.InitAPIKeyStore()
+	handlers.InitAuditLog()
+	handlers.InitStudioIndex()
+	handlers.InitStudioThumbSources()
+	handlers.InitThumbCache()
+	handlers.MigrateConfigFile()
+
+	watcher := handlers.StartFileWatcher()
+	if watcher != nil {
+		defer watcher.Close()
+	}
 
 	job := collector.RegisterStatsJob()
 	if job != nil {
 		defer job.Stop()
 	}
 
-	var protected fiber.Handler
+	if indexJob := handlers.RegisterStudioIndexJob(); indexJob != nil {
+		defer indexJob.Stop()
+	}
+
+	var protected, protectedServer func(models.Permission) fiber.Handler
 	if isAuthEnabled() {
-		protected = handlers.AuthMiddleware
+		protected = handlers.RequirePermission
+		protectedServer = handlers.RequireServerPermission
 	} else {
-		protected = func(c fiber.Ctx) error { return c.Next() }
+		protected = func(models.Permission) fiber.Handler {
+			return func(c fiber.Ctx) error { return c.Next() }
+		}
+		protectedServer = protected
 	}
 
 	api := app.Group("/api")
 
 	api.Get("/config", handlers.GetConfig)
-	api.Post("/config", protected, handlers.UpdateConfig)
+	api.Post("/config", protected(models.PermissionManageConfig), handlers.UpdateConfig)
+	api.Patch("/config", protected(models.PermissionManageConfig), handlers.PatchConfig)
+	api.Get("/config/schema", handlers.GetConfigSchema)
+	api.Get("/config/user", handlers.GetUserConfigOverlay)
+	api.Put("/config/user", handlers.UpdateUserConfigOverlay)
+	api.Delete("/config/user", handlers.DeleteUserConfigOverlay)
+	api.Get("/config/revisions", protected(models.PermissionManageConfig), handlers.GetConfigRevisions)
+	api.Get("/config/revisions/:id", protected(models.PermissionManageConfig), handlers.GetConfigRevision)
+	api.Post("/config/revisions/:id/rollback", protected(models.PermissionManageConfig), handlers.RollbackConfig)
+	api.Get("/config/diff", protected(models.PermissionManageConfig), handlers.DiffConfigRevisions)
 	api.Get("/branding/logo/:mode", handlers.GetBrandingLogo)
-	api.Post("/branding/logo/:mode", protected, handlers.UploadBrandingLogo)
-	api.Delete("/branding/logo/:mode", protected, handlers.ResetBrandingLogo)
+	api.Post("/branding/logo/:mode", protected(models.PermissionManageBranding), handlers.UploadBrandingLogo)
+	api.Delete("/branding/logo/:mode", protected(models.PermissionManageBranding), handlers.ResetBrandingLogo)
+	api.Get("/branding/icon/:size?", handlers.GetBrandingIcon)
+	api.Post("/branding/icon", protected(models.PermissionManageBranding), handlers.UploadBrandingIcon)
+	api.Delete("/branding/icon", protected(models.PermissionManageBranding), handlers.ResetBrandingIcon)
+	api.Get("/branding/login-background", handlers.GetLoginBackground)
+	api.Post("/branding/login-background", protected(models.PermissionManageBranding), handlers.UploadLoginBackground)
+	api.Delete("/branding/login-background", protected(models.PermissionManageBranding), handlers.ResetLoginBackground)
+	api.Get("/branding/manifest.webmanifest", handlers.GetWebManifest)
 
 	api.Get("/themes", handlers.GetThemes)
-	api.Post("/themes", protected, handlers.CreateTheme)
+	api.Post("/themes", protected(models.PermissionManageThemes), handlers.CreateTheme)
 	api.Get("/themes/:id", handlers.GetTheme)
-	api.Put("/themes/:id", protected, handlers.UpdateTheme)
-	api.Delete("/themes/:id", protected, handlers.DeleteTheme)
-	api.Post("/themes/:id/install", protected, handlers.InstallTheme)
+	api.Put("/themes/:id", protected(models.PermissionManageThemes), handlers.UpdateTheme)
+	api.Delete("/themes/:id", protected(models.PermissionManageThemes), handlers.DeleteTheme)
+	api.Post("/themes/:id/install", protected(models.PermissionManageThemes), handlers.InstallTheme)
 
 	api.Get("/studios", handlers.GetStudios)
 	api.Get("/studios/:name/thumb", handlers.GetStudioThumb)
+	api.Post("/studios/:name/thumb", protected(models.PermissionManageBranding), handlers.UploadStudioThumb)
+	api.Delete("/studios/:name/thumb", protected(models.PermissionManageBranding), handlers.DeleteStudioThumb)
+	api.Get("/facets/:kind", handlers.GetFacets)
+
+	api.Get("/events", handlers.GetEvents)
+
+	api.Post("/auth/logout", handlers.Logout)
+	api.Get("/auth/permissions", handlers.GetMyPermissions)
+	api.Get("/auth/cache-stats", protected(models.PermissionAdmin), handlers.GetAuthCacheStats)
+
+	api.Get("/admin/export", protected(models.PermissionAdmin), handlers.ExportBundle)
+	api.Post("/admin/import", protected(models.PermissionAdmin), handlers.ImportBundle)
+	api.Get("/admin/roles", protected(models.PermissionAdmin), handlers.GetRoles)
+	api.Put("/admin/roles", protected(models.PermissionAdmin), handlers.UpdateRoles)
+	api.Get("/admin/api-keys", protected(models.PermissionAdmin), handlers.GetAPIKeys)
+	api.Post("/admin/api-keys", protected(models.PermissionAdmin), handlers.CreateAPIKey)
+	api.Delete("/admin/api-keys/:id", protected(models.PermissionAdmin), handlers.RevokeAPIKey)
+	api.Get("/admin/audit", protected(models.PermissionAdmin), handlers.GetAuditLog)
+	api.Get("/admin/studio-index", protected(models.PermissionAdmin), handlers.GetStudioIndexStatus)
+	api.Get("/admin/studio-thumbs", protected(models.PermissionManageBranding), handlers.GetStudioThumbUploads)
+	api.Get("/admin/studio-thumbs/cache", protected(models.PermissionAdmin), handlers.GetThumbCacheStats)
+	api.Delete("/admin/studio-thumbs/cache", protected(models.PermissionAdmin), handlers.PurgeThumbCache)
+	api.Delete("/admin/studio-thumbs/cache/:name", protected(models.PermissionAdmin), handlers.PurgeThumbCache)
+	api.Get("/admin/servers", protected(models.PermissionAdmin), handlers.GetServerRegistry)
+	api.Put("/admin/servers", protected(models.PermissionAdmin), handlers.UpdateServerRegistry)
+	api.Get("/admin/servers/:id/config", protectedServer(models.PermissionManageConfig), handlers.GetServerConfig)
+	api.Put("/admin/servers/:id/config", protectedServer(models.PermissionManageConfig), handlers.UpdateServerConfig)
+	api.Delete("/admin/servers/:id/config", protectedServer(models.PermissionManageConfig), handlers.DeleteServerConfig)
+
+	api.Get("/servers", handlers.GetServers)
 
 	api.Get("/stats-consent", handlers.GetStatsConsent)
 	api.Post("/stats-consent", handlers.PostStatsConsent)
@@ -1,5 +1,11 @@
 package models
 
 type APIError struct {
-	Error string `json:"error"`
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
 }
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// APIKeyPrefix starts every key minted by the backend so keys can be told
+// apart from Jellyfin access tokens.
+const APIKeyPrefix = "pk_"
+
+// APIKey is the metadata of a backend-issued API key. The secret itself is
+// only returned once, on creation.
+type APIKey struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Permissions []Permission `json:"permissions"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	CreatedBy   string       `json:"createdBy,omitempty"`
+	LastUsedAt  *time.Time   `json:"lastUsedAt,omitempty"`
+	ExpiresAt   *time.Time   `json:"expiresAt,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name        string       `json:"name"`
+	Permissions []Permission `json:"permissions"`
+	ExpiresAt   *time.Time   `json:"expiresAt,omitempty"`
+}
+
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+func (r *CreateAPIKeyRequest) Validate() []FieldError {
+	v := &configValidator{}
+
+	if r.Name == "" {
+		v.add("/name", "name is required")
+	}
+	if len(r.Permissions) == 0 {
+		v.add("/permissions", "at least one permission is required")
+	}
+	checkEachOneOf(v, "/permissions", r.Permissions, Permissions)
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+		v.add("/expiresAt", "must be in the future")
+	}
+
+	return v.errors
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// AuditEntry records one administrative change. ActorID is a Jellyfin user
+// ID or "api-key:<id>"; both actor fields are empty when auth is disabled.
+type AuditEntry struct {
+	Time      time.Time      `json:"time"`
+	ActorID   string         `json:"actorId,omitempty"`
+	ActorName string         `json:"actorName,omitempty"`
+	Action    string         `json:"action"`
+	Target    string         `json:"target,omitempty"`
+	Changes   []ConfigChange `json:"changes,omitempty"`
+	Details   any            `json:"details,omitempty"`
+}
+
+const (
+	AuditConfigUpdate       = "config.update"
+	AuditConfigPatch        = "config.patch"
+	AuditConfigRollback     = "config.rollback"
+	AuditThemeCreate        = "theme.create"
+	AuditThemeUpdate        = "theme.update"
+	AuditThemeDelete        = "theme.delete"
+	AuditThemeInstall       = "theme.install"
+	AuditLogoUpload         = "branding.logo.upload"
+	AuditLogoReset          = "branding.logo.reset"
+	AuditIconUpload         = "branding.icon.upload"
+	AuditIconReset          = "branding.icon.reset"
+	AuditBackgroundUpload   = "branding.login-background.upload"
+	AuditBackgroundReset    = "branding.login-background.reset"
+	AuditBundleImport       = "bundle.import"
+	AuditRolesUpdate        = "roles.update"
+	AuditAPIKeyCreate       = "api-key.create"
+	AuditAPIKeyRevoke       = "api-key.revoke"
+	AuditServersUpdate      = "servers.update"
+	AuditServerConfigUpdate = "server-config.update"
+	AuditServerConfigReset  = "server-config.reset"
+	AuditStudioThumbUpload  = "branding.studio-thumb.upload"
+	AuditStudioThumbDelete  = "branding.studio-thumb.delete"
+	AuditThumbCachePurge    = "studio-thumbs.cache.purge"
+)
+
+// AuditFilter selects audit entries; zero fields match everything.
+type AuditFilter struct {
+	Actor   string
+	Actions []string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
@@ -0,0 +1,22 @@
+package models
+
+// WebManifest is the web app manifest browsers use when Pelagica is
+// installed to a home screen.
+type WebManifest struct {
+	Name            string            `json:"name"`
+	ShortName       string            `json:"short_name"`
+	Description     string            `json:"description"`
+	Display         string            `json:"display"`
+	Categories      []string          `json:"categories"`
+	StartURL        string            `json:"start_url"`
+	Icons           []WebManifestIcon `json:"icons"`
+	ThemeColor      string            `json:"theme_color"`
+	BackgroundColor string            `json:"background_color"`
+}
+
+type WebManifestIcon struct {
+	Src     string `json:"src"`
+	Sizes   string `json:"sizes"`
+	Type    string `json:"type"`
+	Purpose string `json:"purpose,omitempty"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	BundleFormat        = "pelagica-export"
+	BundleFormatVersion = 1
+)
+
+type BundleManifest struct {
+	Format        string    `json:"format"`
+	FormatVersion int       `json:"formatVersion"`
+	AppVersion    string    `json:"appVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Themes        []string  `json:"themes"`
+	Logos         []string  `json:"logos"`
+	Assets        []string  `json:"assets,omitempty"`
+	StatsConsent  *bool     `json:"statsConsent,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun        bool           `json:"dryRun"`
+	Mode          string         `json:"mode"`
+	ConfigChanges []ConfigChange `json:"configChanges"`
+	ThemesCreated []string       `json:"themesCreated"`
+	ThemesUpdated []string       `json:"themesUpdated"`
+	ThemesDeleted []string       `json:"themesDeleted"`
+	LogosUpdated  []string       `json:"logosUpdated"`
+	LogosRemoved  []string       `json:"logosRemoved"`
+	AssetsUpdated []string       `json:"assetsUpdated"`
+	AssetsRemoved []string       `json:"assetsRemoved"`
+	StatsConsent  *bool          `json:"statsConsent,omitempty"`
+}
+
+const (
+	ImportModeMerge   = "merge"
+	ImportModeReplace = "replace"
+)
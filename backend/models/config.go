@@ -2,11 +2,14 @@ package models
 
 type AppConfig struct {
 	JsonSchema                  string              `json:"$schema,omitempty"`
+	ConfigVersion               int                 `json:"configVersion,omitempty"`
 	HomeScreenSections          []HomeScreenSection `json:"homeScreenSections,omitempty"`
 	ItemPage                    *ItemPageSettings   `json:"itemPage,omitempty"`
 	ServerAddress               string              `json:"serverAddress,omitempty"`
 	LogoLightURL                string              `json:"logoLightUrl,omitempty"`
 	LogoDarkURL                 string              `json:"logoDarkUrl,omitempty"`
+	IconURL                     string              `json:"iconUrl,omitempty"`
+	LoginBackgroundURL          string              `json:"loginBackgroundUrl,omitempty"`
 	ShowStreamystatsButton      *bool               `json:"showStreamystatsButton,omitempty"`
 	StreamystatsURL             string              `json:"streamystatsUrl,omitempty"`
 	WatchedStateBadgeHomeScreen *bool               `json:"watchedStateBadgeHomeScreen,omitempty"`
@@ -16,6 +19,7 @@ type AppConfig struct {
 	ServerThemeId               string              `json:"serverThemeId,omitempty"`
 	ServerName                  string              `json:"serverName,omitempty"`
 	Links                       []ConfigLink        `json:"links,omitempty"`
+	LockedFields                []string            `json:"lockedFields,omitempty"`
 }
 
 type ConfigLink struct {
@@ -103,4 +107,147 @@ const (
 	SectionRecommended   = "streamystatsRecommended"
 	SectionGenres        = "genres"
 	SectionLibraries     = "libraries"
+	SectionStudios       = "studios"
 )
+
+var SectionTypes = []string{
+	SectionMediaBar,
+	SectionItems,
+	SectionRecentlyAdded,
+	SectionContinue,
+	SectionNextUp,
+	SectionResume,
+	SectionRecommended,
+	SectionGenres,
+	SectionLibraries,
+	SectionStudios,
+}
+
+var MediaBarSizes = []string{"small", "medium", "large"}
+
+var SortOrders = []string{"Ascending", "Descending"}
+
+var EpisodeDisplays = []EpisodeDisplay{"grid", "row"}
+
+var RecommendationTypeFilters = []RecommendationTypeFilter{"all", "Movie", "Series"}
+
+var ContinueWatchingTitleLines = []ContinueWatchingTitleLine{
+	"ItemTitle",
+	"ParentTitle",
+	"ItemTitleWithEpisodeInfo",
+}
+
+var ContinueWatchingDetailLines = []ContinueWatchingDetailLine{
+	"ProgressPercentage",
+	"TimeRemaining",
+	"EpisodeInfo",
+	"EndsAt",
+	"ParentTitle",
+	"None",
+}
+
+var DetailFields = []DetailField{
+	"ReleaseYear",
+	"ReleaseYearAndMonth",
+	"ReleaseDate",
+	"CommunityRating",
+	"PlayDuration",
+	"PlayEnd",
+	"SeasonCount",
+	"EpisodeCount",
+	"AgeRating",
+	"Artist",
+	"TrackCount",
+}
+
+var DetailBadges = []DetailBadge{
+	"ReleaseYear",
+	"ReleaseYearAndMonth",
+	"ReleaseDate",
+	"CommunityRating",
+	"PlayDuration",
+	"PlayEnd",
+	"SeasonCount",
+	"EpisodeCount",
+	"AgeRating",
+	"EpisodeNumber",
+	"Duration",
+	"VideoQuality",
+}
+
+// BaseItemKinds mirrors the BaseItemKind enum of the Jellyfin API.
+var BaseItemKinds = []BaseItemKind{
+	"AggregateFolder",
+	"Audio",
+	"AudioBook",
+	"BasePluginFolder",
+	"Book",
+	"BoxSet",
+	"Channel",
+	"ChannelFolderItem",
+	"CollectionFolder",
+	"Episode",
+	"Folder",
+	"Genre",
+	"ManualPlaylistsFolder",
+	"Movie",
+	"LiveTvChannel",
+	"LiveTvProgram",
+	"MusicAlbum",
+	"MusicArtist",
+	"MusicGenre",
+	"MusicVideo",
+	"Person",
+	"Photo",
+	"PhotoAlbum",
+	"Playlist",
+	"PlaylistsFolder",
+	"Program",
+	"Recording",
+	"Season",
+	"Series",
+	"Studio",
+	"Trailer",
+	"TvChannel",
+	"TvProgram",
+	"UserRootFolder",
+	"UserView",
+	"Video",
+	"Year",
+}
+
+// ItemSortBys mirrors the ItemSortBy enum of the Jellyfin API.
+var ItemSortBys = []ItemSortBy{
+	"Default",
+	"AiredEpisodeOrder",
+	"Album",
+	"AlbumArtist",
+	"Artist",
+	"DateCreated",
+	"OfficialRating",
+	"DatePlayed",
+	"PremiereDate",
+	"StartDate",
+	"SortName",
+	"Name",
+	"Random",
+	"Runtime",
+	"CommunityRating",
+	"ProductionYear",
+	"PlayCount",
+	"CriticRating",
+	"IsFolder",
+	"IsUnplayed",
+	"IsPlayed",
+	"SeriesSortName",
+	"VideoBitRate",
+	"AirTime",
+	"Studio",
+	"IsFavoriteOrLiked",
+	"DateLastContentAdded",
+	"SeriesDatePlayed",
+	"ParentIndexNumber",
+	"IndexNumber",
+	"SimilarityScore",
+	"SearchScore",
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type configValidator struct {
+	errors []FieldError
+}
+
+func (v *configValidator) add(path, format string, args ...any) {
+	v.errors = append(v.errors, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func checkOneOf[T ~string](v *configValidator, path string, value T, allowed []T) {
+	if value == "" || slices.Contains(allowed, value) {
+		return
+	}
+	v.add(path, "must be one of %s", joinValues(allowed))
+}
+
+func checkEachOneOf[T ~string](v *configValidator, path string, values []T, allowed []T) {
+	for i, value := range values {
+		elemPath := path + "/" + strconv.Itoa(i)
+		if value == "" {
+			v.add(elemPath, "must not be empty")
+			continue
+		}
+		checkOneOf(v, elemPath, value, allowed)
+	}
+}
+
+func checkLimit(v *configValidator, path string, limit *int) {
+	if limit != nil && *limit <= 0 {
+		v.add(path, "must be greater than 0")
+	}
+}
+
+func checkAbsoluteURL(v *configValidator, path, raw string) {
+	if raw == "" {
+		return
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(path, "must be an absolute http or https URL")
+	}
+}
+
+// checkConfigPointer verifies that pointer addresses an object field of
+// AppConfig. Array elements cannot be addressed because merge patches
+// replace arrays as a whole.
+func checkConfigPointer(v *configValidator, path, pointer string) {
+	if !strings.HasPrefix(pointer, "/") || pointer == "/" {
+		v.add(path, "must be a JSON pointer such as /homeScreenSections")
+		return
+	}
+
+	t := reflect.TypeFor[AppConfig]()
+	for _, token := range strings.Split(pointer[1:], "/") {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			v.add(path, "must not point inside a list or value")
+			return
+		}
+
+		found := false
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name == token {
+				t = t.Field(i).Type
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.add(path, "unknown config field %q", token)
+			return
+		}
+	}
+}
+
+func joinValues[T ~string](values []T) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = string(value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Validate checks the whole config tree for semantic errors and returns one
+// entry per offending field. Paths are JSON pointers (RFC 6901).
+func (c *AppConfig) Validate() []FieldError {
+	v := &configValidator{}
+
+	checkAbsoluteURL(v, "/serverAddress", c.ServerAddress)
+	checkAbsoluteURL(v, "/streamystatsUrl", c.StreamystatsURL)
+
+	for i, section := range c.HomeScreenSections {
+		section.validate(v, "/homeScreenSections/"+strconv.Itoa(i))
+	}
+
+	if c.ItemPage != nil {
+		c.ItemPage.validate(v, "/itemPage")
+	}
+
+	for i, link := range c.Links {
+		path := "/links/" + strconv.Itoa(i)
+		if strings.TrimSpace(link.Text) == "" {
+			v.add(path+"/text", "is required")
+		}
+		if strings.TrimSpace(link.URL) == "" {
+			v.add(path+"/url", "is required")
+			continue
+		}
+		u, err := url.Parse(link.URL)
+		if err != nil || (u.Scheme == "" && !strings.HasPrefix(link.URL, "/")) || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+			v.add(path+"/url", "must be an http(s) URL or an absolute path")
+		}
+	}
+
+	for i, pointer := range c.LockedFields {
+		checkConfigPointer(v, "/lockedFields/"+strconv.Itoa(i), pointer)
+	}
+
+	return v.errors
+}
+
+func (s *HomeScreenSection) validate(v *configValidator, path string) {
+	if s.Type == "" {
+		v.add(path+"/type", "is required")
+	} else {
+		checkOneOf(v, path+"/type", s.Type, SectionTypes)
+	}
+
+	checkOneOf(v, path+"/size", s.Size, MediaBarSizes)
+	checkLimit(v, path+"/limit", s.Limit)
+	checkEachOneOf(v, path+"/detailFields", s.DetailFields, DetailFields)
+	checkEachOneOf(v, path+"/detailLine", s.DetailLine, ContinueWatchingDetailLines)
+	checkOneOf(v, path+"/titleLine", s.TitleLine, ContinueWatchingTitleLines)
+	checkOneOf(v, path+"/recommendationType", s.RecommendationType, RecommendationTypeFilters)
+
+	if s.Items != nil {
+		s.Items.validate(v, path+"/items")
+	}
+}
+
+func (s *SectionItemsConfig) validate(v *configValidator, path string) {
+	checkLimit(v, path+"/limit", s.Limit)
+	checkEachOneOf(v, path+"/sortBy", s.SortBy, ItemSortBys)
+	checkOneOf(v, path+"/sortOrder", s.SortOrder, SortOrders)
+
+	for i, t := range s.Types {
+		elemPath := path + "/types/" + strconv.Itoa(i)
+		if !slices.Contains(BaseItemKinds, BaseItemKind(t)) {
+			v.add(elemPath, "must be a Jellyfin item kind")
+		}
+	}
+}
+
+func (s *ItemPageSettings) validate(v *configValidator, path string) {
+	checkEachOneOf(v, path+"/detailBadges", s.DetailBadges, DetailBadges)
+	checkOneOf(v, path+"/episodeDisplay", s.EpisodeDisplay, EpisodeDisplays)
+	checkEachOneOf(v, path+"/favoriteButton", s.FavoriteButton, BaseItemKinds)
+	checkEachOneOf(v, path+"/deleteButton", s.DeleteButton, BaseItemKinds)
+}
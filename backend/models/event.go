@@ -0,0 +1,18 @@
+package models
+
+type Event struct {
+	Type string `json:"type"`
+	Data any    `json:"data,omitempty"`
+}
+
+const (
+	EventConfigUpdated       = "config-updated"
+	EventThemeCreated        = "theme-created"
+	EventThemeUpdated        = "theme-updated"
+	EventThemeDeleted        = "theme-deleted"
+	EventBrandingLogoChanged = "branding-logo-changed"
+	EventBrandingIconChanged = "branding-icon-changed"
+	EventBackgroundChanged   = "branding-login-background-changed"
+	EventServerThemeChanged  = "server-theme-changed"
+	EventStudioThumbChanged  = "studio-thumb-changed"
+)
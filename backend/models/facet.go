@@ -0,0 +1,59 @@
+package models
+
+type FacetKind string
+
+const (
+	FacetStudios  FacetKind = "studios"
+	FacetNetworks FacetKind = "networks"
+	FacetGenres   FacetKind = "genres"
+	FacetTags     FacetKind = "tags"
+	FacetYears    FacetKind = "years"
+	FacetDecades  FacetKind = "decades"
+	FacetRatings  FacetKind = "ratings"
+	FacetPeople   FacetKind = "people"
+)
+
+var FacetKinds = []FacetKind{
+	FacetStudios,
+	FacetNetworks,
+	FacetGenres,
+	FacetTags,
+	FacetYears,
+	FacetDecades,
+	FacetRatings,
+	FacetPeople,
+}
+
+const (
+	FacetSortCount = "count"
+	FacetSortName  = "name"
+)
+
+var FacetSortOptions = []string{FacetSortCount, FacetSortName}
+
+// FacetItemTypes are the item types the studio index covers.
+var FacetItemTypes = []string{"Movie", "Series"}
+
+// FacetFilter narrows the items a facet is counted over. Empty fields match
+// everything; Role only applies to people.
+type FacetFilter struct {
+	LibraryIDs []string
+	ItemTypes  []string
+	Role       string
+}
+
+type FacetValue struct {
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type FacetResponse struct {
+	Kind        FacetKind    `json:"kind"`
+	Sort        string       `json:"sort"`
+	Order       string       `json:"order"`
+	SortOptions []string     `json:"sortOptions"`
+	Total       int          `json:"total"`
+	StartIndex  int          `json:"startIndex"`
+	Items       []FacetValue `json:"items"`
+}
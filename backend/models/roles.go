@@ -0,0 +1,137 @@
+package models
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+type Permission string
+
+const (
+	PermissionAdmin          Permission = "admin"
+	PermissionManageConfig   Permission = "config"
+	PermissionManageThemes   Permission = "themes"
+	PermissionManageBranding Permission = "branding"
+)
+
+var Permissions = []Permission{
+	PermissionAdmin,
+	PermissionManageConfig,
+	PermissionManageThemes,
+	PermissionManageBranding,
+}
+
+// RoleAssignments delegates permissions to Jellyfin users that are not
+// Jellyfin administrators. Groups are named lists of Jellyfin user IDs.
+// User IDs are only unique per Jellyfin server, so each role applies to the
+// users of one server.
+type RoleAssignments struct {
+	Groups map[string][]string `json:"groups"`
+	Roles  map[string]Role     `json:"roles"`
+}
+
+type Role struct {
+	// Server is the ID of the registered server whose users the role
+	// applies to, or empty for the server configured outside the registry.
+	Server      string       `json:"server,omitempty"`
+	Permissions []Permission `json:"permissions"`
+	Users       []string     `json:"users,omitempty"`
+	Groups      []string     `json:"groups,omitempty"`
+}
+
+var jellyfinUserIDPattern = regexp.MustCompile(`^[0-9a-fA-F-]{32,36}$`)
+
+// IsJellyfinUserID reports whether id looks like a Jellyfin user ID, which
+// also makes it safe to use as a file name.
+func IsJellyfinUserID(id string) bool {
+	return jellyfinUserIDPattern.MatchString(id)
+}
+
+// PermissionsFor returns the permissions granted to userID of serverID
+// through any role, either directly or through group membership.
+func (r *RoleAssignments) PermissionsFor(serverID, userID string) []Permission {
+	granted := []Permission{}
+
+	for _, role := range r.Roles {
+		if role.Server != serverID {
+			continue
+		}
+
+		member := slices.Contains(role.Users, userID)
+		for _, group := range role.Groups {
+			if member {
+				break
+			}
+			member = slices.Contains(r.Groups[group], userID)
+		}
+		if !member {
+			continue
+		}
+
+		for _, permission := range role.Permissions {
+			if !slices.Contains(granted, permission) {
+				granted = append(granted, permission)
+			}
+		}
+	}
+
+	slices.Sort(granted)
+	return granted
+}
+
+func (r *RoleAssignments) Validate() []FieldError {
+	v := &configValidator{}
+
+	groupNames := make([]string, 0, len(r.Groups))
+	for name := range r.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+
+	for _, name := range groupNames {
+		path := "/groups/" + name
+		if name == "" {
+			v.add(path, "group name must not be empty")
+		}
+		for i, userID := range r.Groups[name] {
+			if !IsJellyfinUserID(userID) {
+				v.add(path+"/"+strconv.Itoa(i), "must be a Jellyfin user ID")
+			}
+		}
+	}
+
+	roleNames := make([]string, 0, len(r.Roles))
+	for name := range r.Roles {
+		roleNames = append(roleNames, name)
+	}
+	sort.Strings(roleNames)
+
+	for _, name := range roleNames {
+		role := r.Roles[name]
+		path := "/roles/" + name
+		if name == "" {
+			v.add(path, "role name must not be empty")
+		}
+		if role.Server != "" && !IsServerID(role.Server) {
+			v.add(path+"/server", "must be a server ID")
+		}
+		if len(role.Permissions) == 0 {
+			v.add(path+"/permissions", "at least one permission is required")
+		}
+		checkEachOneOf(v, path+"/permissions", role.Permissions, Permissions)
+		for i, userID := range role.Users {
+			if !IsJellyfinUserID(userID) {
+				v.add(path+"/users/"+strconv.Itoa(i), "must be a Jellyfin user ID")
+			}
+		}
+		for i, group := range role.Groups {
+			if _, ok := r.Groups[group]; !ok {
+				v.add(path+"/groups/"+strconv.Itoa(i), "unknown group %q", group)
+			}
+		}
+	}
+
+	return v.errors
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JellyfinServer is one named Jellyfin server served by this backend.
+// BackendURL overrides how the backend reaches it, like
+// JELLYFIN_BACKEND_URL does for a single server.
+type JellyfinServer struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ServerAddress string `json:"serverAddress"`
+	BackendURL    string `json:"backendUrl,omitempty"`
+}
+
+// ServerSummary is the public view of a server, without internal URLs.
+type ServerSummary struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ServerAddress string `json:"serverAddress"`
+}
+
+type ServerRegistry struct {
+	Servers []JellyfinServer `json:"servers"`
+}
+
+var serverIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// IsServerID reports whether id is a valid server identifier, which also
+// makes it safe to use as a file name.
+func IsServerID(id string) bool {
+	return serverIDPattern.MatchString(id)
+}
+
+// Find returns the server with the given ID.
+func (r *ServerRegistry) Find(id string) (JellyfinServer, bool) {
+	for _, server := range r.Servers {
+		if server.ID == id {
+			return server, true
+		}
+	}
+	return JellyfinServer{}, false
+}
+
+func (r *ServerRegistry) Validate() []FieldError {
+	v := &configValidator{}
+
+	seenIDs := map[string]bool{}
+	seenAddresses := map[string]bool{}
+	for i, server := range r.Servers {
+		path := "/servers/" + strconv.Itoa(i)
+
+		if !IsServerID(server.ID) {
+			v.add(path+"/id", "must be lower-case letters, digits and dashes")
+		} else if seenIDs[server.ID] {
+			v.add(path+"/id", "duplicate server id %q", server.ID)
+		}
+		seenIDs[server.ID] = true
+
+		if strings.TrimSpace(server.Name) == "" {
+			v.add(path+"/name", "name is required")
+		}
+
+		if server.ServerAddress == "" {
+			v.add(path+"/serverAddress", "serverAddress is required")
+		}
+		checkAbsoluteURL(v, path+"/serverAddress", server.ServerAddress)
+
+		address := strings.ToLower(strings.TrimSuffix(server.ServerAddress, "/"))
+		if address != "" && seenAddresses[address] {
+			v.add(path+"/serverAddress", "another server already uses this address")
+		}
+		seenAddresses[address] = true
+
+		checkAbsoluteURL(v, path+"/backendUrl", server.BackendURL)
+	}
+
+	return v.errors
+}
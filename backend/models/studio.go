@@ -1,8 +1,59 @@
 package models
 
+import "time"
+
 type StudioSummary struct {
 	ID       string `json:"id"`
 	Name     string `json:"name"`
 	Count    int    `json:"count"`
 	HasThumb bool   `json:"hasThumb,omitempty"`
 }
+
+// StudioIndexStatus describes one studio index, built per server and user.
+type StudioIndexStatus struct {
+	ServerID            string     `json:"serverId,omitempty"`
+	JellyfinURL         string     `json:"jellyfinUrl"`
+	UserID              string     `json:"userId"`
+	Libraries           int        `json:"libraries"`
+	Items               int        `json:"items"`
+	Studios             int        `json:"studios"`
+	LastBuildAt         *time.Time `json:"lastBuildAt,omitempty"`
+	LastBuildDurationMs int64      `json:"lastBuildDurationMs"`
+	LastBuildType       string     `json:"lastBuildType,omitempty"`
+	LastFullBuildAt     *time.Time `json:"lastFullBuildAt,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	Building            bool       `json:"building"`
+	Scheduled           bool       `json:"scheduled"`
+}
+
+const (
+	StudioIndexBuildFull        = "full"
+	StudioIndexBuildIncremental = "incremental"
+)
+
+// StudioThumbSourceLocal marks thumbs uploaded by an admin, as opposed to
+// thumbs from a remote catalog, which are identified by their base URL.
+const StudioThumbSourceLocal = "local"
+
+// StudioThumb describes an admin-uploaded studio thumbnail.
+type StudioThumb struct {
+	Name        string    `json:"name"`
+	Source      string    `json:"source"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+}
+
+// ThumbCacheStats describes the on-disk cache of thumbs downloaded from
+// remote sources. Counters are reset when the server restarts.
+type ThumbCacheStats struct {
+	Entries         int   `json:"entries"`
+	SizeBytes       int64 `json:"sizeBytes"`
+	MaxSizeBytes    int64 `json:"maxSizeBytes"`
+	NegativeEntries int   `json:"negativeEntries"`
+	Hits            int64 `json:"hits"`
+	Misses          int64 `json:"misses"`
+	NegativeHits    int64 `json:"negativeHits"`
+	Revalidations   int64 `json:"revalidations"`
+	Evictions       int64 `json:"evictions"`
+}
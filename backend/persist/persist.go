@@ -0,0 +1,94 @@
+// Package persist provides crash-safe file writes for the backend's
+// persistent state (config, themes, branding assets and collector files).
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BackupSuffix is appended to the path of the previous version kept by
+// WriteFileWithBackup.
+const BackupSuffix = ".bak"
+
+// WriteFile atomically replaces path with data. The content is written to a
+// temp file in the same directory, fsynced and renamed over path, so readers
+// only ever see the old or the new content, never a truncated file.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+
+	cleanup := func() {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+	}
+
+	if _, err := tmpFile.Write(data); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmpFile.Chmod(perm); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// WriteFileWithBackup works like WriteFile but first copies the current
+// content of path, if any, to path+BackupSuffix.
+func WriteFileWithBackup(path string, data []byte, perm os.FileMode) error {
+	previous, err := os.ReadFile(path)
+	if err == nil {
+		if err := WriteFile(path+BackupSuffix, previous, perm); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	return WriteFile(path, data, perm)
+}
+
+// syncDir flushes directory metadata so the rename survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// Some filesystems do not support fsync on directories; the rename has
+	// already happened at this point, so that is not treated as a failure.
+	d.Sync()
+	return nil
+}
+
+// IsTempFile reports whether name looks like an in-progress temp file
+// created by WriteFile.
+func IsTempFile(name string) bool {
+	base := filepath.Base(name)
+	return strings.HasPrefix(base, ".") && strings.HasPrefix(filepath.Ext(base), ".tmp-")
+}
@@ -0,0 +1,122 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filepath.Base(path), err)
+	}
+	return string(data)
+}
+
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if IsTempFile(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func TestWriteFileReplacesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "config.json")
+
+	if err := WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := WriteFile(path, []byte("new"), 0644); err != nil {
+		t.Fatalf("WriteFile over existing file: %v", err)
+	}
+
+	if got := readFile(t, path); got != "new" {
+		t.Errorf("content = %q, want %q", got, "new")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0644 {
+		t.Errorf("mode = %v, want %v", got, os.FileMode(0644))
+	}
+	if names := tempFiles(t, filepath.Dir(path)); len(names) > 0 {
+		t.Errorf("temp files left behind: %v", names)
+	}
+}
+
+func TestWriteFileRemovesTempFileOnError(t *testing.T) {
+	dir := t.TempDir()
+
+	// A non-empty directory at path makes the final rename fail.
+	path := filepath.Join(dir, "config.json")
+	if err := os.MkdirAll(filepath.Join(path, "child"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFile(path, []byte("data"), 0644); err == nil {
+		t.Fatal("WriteFile over a directory succeeded, want an error")
+	}
+	if names := tempFiles(t, dir); len(names) > 0 {
+		t.Errorf("temp files left behind: %v", names)
+	}
+}
+
+func TestWriteFileWithBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "theme.json")
+
+	if err := WriteFileWithBackup(path, []byte("first"), 0644); err != nil {
+		t.Fatalf("WriteFileWithBackup: %v", err)
+	}
+	if _, err := os.Stat(path + BackupSuffix); !os.IsNotExist(err) {
+		t.Errorf("backup written for a new file: %v", err)
+	}
+
+	if err := WriteFileWithBackup(path, []byte("second"), 0644); err != nil {
+		t.Fatalf("WriteFileWithBackup: %v", err)
+	}
+	if err := WriteFileWithBackup(path, []byte("third"), 0644); err != nil {
+		t.Fatalf("WriteFileWithBackup: %v", err)
+	}
+
+	if got := readFile(t, path); got != "third" {
+		t.Errorf("content = %q, want %q", got, "third")
+	}
+	if got := readFile(t, path+BackupSuffix); got != "second" {
+		t.Errorf("backup = %q, want the previous content %q", got, "second")
+	}
+}
+
+func TestIsTempFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{".config.json.tmp-123456", true},
+		{"/data/.thumb.webp.tmp-1", true},
+		{"config.json", false},
+		{".hidden", false},
+		{"config.json.tmp-123456", false},
+		{"config.json" + BackupSuffix, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTempFile(tt.name); got != tt.want {
+			t.Errorf("IsTempFile(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pelagica-backend/models"
+	"pelagica-backend/persist"
+)
+
+// lastUsedPersistInterval throttles how often a key's last-used timestamp
+// is written to disk; in memory it is always current.
+const lastUsedPersistInterval = time.Minute
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyInvalid  = errors.New("invalid api key")
+)
+
+type storedAPIKey struct {
+	models.APIKey
+	Hash string `json:"hash"`
+
+	// lastUsedPersisted is when LastUsedAt was last written to disk.
+	lastUsedPersisted time.Time
+}
+
+// APIKeyStore persists backend-issued API keys in a single JSON file. Only
+// a SHA-256 hash of each key is stored.
+type APIKeyStore struct {
+	path string
+	keys map[string]*storedAPIKey
+	mu   sync.Mutex
+}
+
+func NewAPIKeyStore(path string) (*APIKeyStore, error) {
+	store := &APIKeyStore{path: path, keys: map[string]*storedAPIKey{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil && len(data) > 0 {
+		var keys []*storedAPIKey
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			store.keys[key.ID] = key
+		}
+	}
+
+	return store, nil
+}
+
+func (s *APIKeyStore) Path() string {
+	return s.path
+}
+
+// List returns the metadata of all keys, newest first.
+func (s *APIKeyStore) List() []models.APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]models.APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key.APIKey)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys
+}
+
+// Create mints a new key. The returned secret is not stored and cannot be
+// recovered later.
+func (s *APIKeyStore) Create(req models.CreateAPIKeyRequest, createdBy string) (models.CreatedAPIKey, error) {
+	idBytes := make([]byte, 8)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(idBytes); err != nil {
+		return models.CreatedAPIKey{}, err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return models.CreatedAPIKey{}, err
+	}
+
+	id := hex.EncodeToString(idBytes)
+	secret := models.APIKeyPrefix + id + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	key := &storedAPIKey{
+		APIKey: models.APIKey{
+			ID:          id,
+			Name:        req.Name,
+			Permissions: req.Permissions,
+			CreatedAt:   time.Now().UTC(),
+			CreatedBy:   createdBy,
+			ExpiresAt:   req.ExpiresAt,
+		},
+		Hash: hashAPIKey(secret),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[id] = key
+	if err := s.persistLocked(); err != nil {
+		delete(s.keys, id)
+		return models.CreatedAPIKey{}, err
+	}
+
+	return models.CreatedAPIKey{APIKey: key.APIKey, Key: secret}, nil
+}
+
+// Revoke deletes a key so it is rejected from now on.
+func (s *APIKeyStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+
+	delete(s.keys, id)
+	if err := s.persistLocked(); err != nil {
+		s.keys[id] = key
+		return err
+	}
+
+	return nil
+}
+
+// Authenticate returns the key matching secret and records its use.
+func (s *APIKeyStore) Authenticate(secret string) (models.APIKey, error) {
+	id, ok := apiKeyID(secret)
+	if !ok {
+		return models.APIKey{}, ErrAPIKeyInvalid
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashAPIKey(secret))) != 1 {
+		return models.APIKey{}, ErrAPIKeyInvalid
+	}
+
+	now := time.Now().UTC()
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return models.APIKey{}, ErrAPIKeyInvalid
+	}
+
+	key.LastUsedAt = &now
+	if now.Sub(key.lastUsedPersisted) >= lastUsedPersistInterval {
+		// Failing to record the timestamp must not reject the request.
+		if err := s.persistLocked(); err == nil {
+			key.lastUsedPersisted = now
+		}
+	}
+
+	return key.APIKey, nil
+}
+
+// IsAPIKey reports whether token looks like a backend-issued key rather
+// than a Jellyfin access token.
+func IsAPIKey(token string) bool {
+	_, ok := apiKeyID(token)
+	return ok
+}
+
+func apiKeyID(secret string) (string, bool) {
+	rest, ok := strings.CutPrefix(secret, models.APIKeyPrefix)
+	if !ok {
+		return "", false
+	}
+
+	id, _, ok := strings.Cut(rest, "_")
+	if !ok || len(id) != 16 {
+		return "", false
+	}
+
+	return id, true
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *APIKeyStore) persistLocked() error {
+	keys := make([]*storedAPIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(keys, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return persist.WriteFile(s.path, data, 0600)
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pelagica-backend/models"
+)
+
+// maxAuditLineBytes bounds a single entry when reading the log back.
+const maxAuditLineBytes = 4 * 1024 * 1024
+
+// AuditLog appends entries to a JSONL file. Once the file would grow past
+// maxBytes it is rotated to <path>.1, <path>.2, ... keeping maxFiles
+// rotated files.
+type AuditLog struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+	mu       sync.Mutex
+}
+
+func NewAuditLog(path string, maxBytes int64, maxFiles int) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	return &AuditLog{path: path, maxBytes: maxBytes, maxFiles: maxFiles}, nil
+}
+
+func (l *AuditLog) rotatedPath(n int) string {
+	return l.path + "." + strconv.Itoa(n)
+}
+
+func (l *AuditLog) Append(entry models.AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if info, err := os.Stat(l.path); err == nil && info.Size() > 0 && info.Size()+int64(len(line)) > l.maxBytes {
+		if err := l.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (l *AuditLog) rotateLocked() error {
+	if l.maxFiles <= 0 {
+		return os.Remove(l.path)
+	}
+
+	os.Remove(l.rotatedPath(l.maxFiles))
+	for n := l.maxFiles - 1; n >= 1; n-- {
+		if err := os.Rename(l.rotatedPath(n), l.rotatedPath(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Rename(l.path, l.rotatedPath(1))
+}
+
+// Query returns the entries matching filter, newest first.
+func (l *AuditLog) Query(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files := []string{}
+	for n := l.maxFiles; n >= 1; n-- {
+		files = append(files, l.rotatedPath(n))
+	}
+	files = append(files, l.path)
+
+	entries := []models.AuditEntry{}
+	for _, path := range files {
+		matched, err := readAuditFile(path, filter)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, matched...)
+	}
+
+	slices.Reverse(entries)
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+
+	return entries, nil
+}
+
+func readAuditFile(path string, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []models.AuditEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditLineBytes)
+	for scanner.Scan() {
+		var entry models.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Skip a line torn by a crash mid-write.
+			continue
+		}
+		if auditEntryMatches(entry, filter) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+func auditEntryMatches(entry models.AuditEntry, filter models.AuditFilter) bool {
+	if filter.Actor != "" && entry.ActorID != filter.Actor && !strings.EqualFold(entry.ActorName, filter.Actor) {
+		return false
+	}
+	if len(filter.Actions) > 0 && !slices.Contains(filter.Actions, entry.Action) {
+		return false
+	}
+	if !filter.Since.IsZero() && entry.Time.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && entry.Time.After(filter.Until) {
+		return false
+	}
+	return true
+}
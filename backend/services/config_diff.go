@@ -0,0 +1,103 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pelagica-backend/models"
+)
+
+// DiffJSON compares two JSON documents and returns the changes needed to get
+// from a to b. Paths are JSON pointers (RFC 6901).
+func DiffJSON(a, b []byte) ([]models.ConfigChange, error) {
+	var left, right any
+
+	if len(a) > 0 {
+		if err := json.Unmarshal(a, &left); err != nil {
+			return nil, err
+		}
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &right); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := []models.ConfigChange{}
+	diffValues("", left, right, &changes)
+	return changes, nil
+}
+
+func escapePointerToken(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return strings.ReplaceAll(token, "/", "~1")
+}
+
+func diffValues(path string, left, right any, changes *[]models.ConfigChange) {
+	switch l := left.(type) {
+	case map[string]any:
+		r, ok := right.(map[string]any)
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(l)+len(r))
+		for k := range l {
+			keys = append(keys, k)
+		}
+		for k := range r {
+			if _, seen := l[k]; !seen {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			childPath := path + "/" + escapePointerToken(k)
+			lv, inLeft := l[k]
+			rv, inRight := r[k]
+
+			switch {
+			case !inLeft:
+				*changes = append(*changes, models.ConfigChange{Path: childPath, Op: models.ConfigChangeAdded, New: rv})
+			case !inRight:
+				*changes = append(*changes, models.ConfigChange{Path: childPath, Op: models.ConfigChangeRemoved, Old: lv})
+			default:
+				diffValues(childPath, lv, rv, changes)
+			}
+		}
+		return
+
+	case []any:
+		r, ok := right.([]any)
+		if !ok {
+			break
+		}
+
+		for i := 0; i < len(l) || i < len(r); i++ {
+			childPath := path + "/" + strconv.Itoa(i)
+
+			switch {
+			case i >= len(l):
+				*changes = append(*changes, models.ConfigChange{Path: childPath, Op: models.ConfigChangeAdded, New: r[i]})
+			case i >= len(r):
+				*changes = append(*changes, models.ConfigChange{Path: childPath, Op: models.ConfigChangeRemoved, Old: l[i]})
+			default:
+				diffValues(childPath, l[i], r[i], changes)
+			}
+		}
+		return
+	}
+
+	if reflect.DeepEqual(left, right) {
+		return
+	}
+
+	if path == "" {
+		path = "/"
+	}
+	*changes = append(*changes, models.ConfigChange{Path: path, Op: models.ConfigChangeChanged, Old: left, New: right})
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pelagica-backend/models"
+	"pelagica-backend/persist"
+)
+
+const configRevisionTimeFormat = "20060102T150405.000000000Z"
+
+// ConfigHistory keeps timestamped copies of every accepted config write so
+// admins can inspect, diff and roll back to earlier revisions.
+type ConfigHistory struct {
+	dir          string
+	maxRevisions int
+	maxAge       time.Duration
+	mu           sync.Mutex
+}
+
+// NewConfigHistory creates a history store in dir. A maxRevisions or maxAge
+// of zero disables that retention limit.
+func NewConfigHistory(dir string, maxRevisions int, maxAge time.Duration) (*ConfigHistory, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &ConfigHistory{
+		dir:          dir,
+		maxRevisions: maxRevisions,
+		maxAge:       maxAge,
+	}, nil
+}
+
+func (h *ConfigHistory) revisionPath(id string) (string, error) {
+	if _, err := time.Parse(configRevisionTimeFormat, id); err != nil {
+		return "", errors.New("invalid revision id")
+	}
+	return filepath.Join(h.dir, id+".json"), nil
+}
+
+// Record stores data as a new revision and applies the retention policy.
+func (h *ConfigHistory) Record(data []byte) (models.ConfigRevision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now().UTC()
+	id := now.Format(configRevisionTimeFormat)
+	path := filepath.Join(h.dir, id+".json")
+
+	if err := persist.WriteFile(path, data, 0644); err != nil {
+		return models.ConfigRevision{}, err
+	}
+
+	h.prune()
+
+	return models.ConfigRevision{
+		ID:        id,
+		CreatedAt: now,
+		Size:      int64(len(data)),
+	}, nil
+}
+
+// List returns all stored revisions, newest first.
+func (h *ConfigHistory) List() ([]models.ConfigRevision, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.list()
+}
+
+func (h *ConfigHistory) list() ([]models.ConfigRevision, error) {
+	files, err := os.ReadDir(h.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]models.ConfigRevision, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		id := strings.TrimSuffix(file.Name(), ".json")
+		createdAt, err := time.Parse(configRevisionTimeFormat, id)
+		if err != nil {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		revisions = append(revisions, models.ConfigRevision{
+			ID:        id,
+			CreatedAt: createdAt,
+			Size:      info.Size(),
+		})
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].CreatedAt.After(revisions[j].CreatedAt)
+	})
+
+	return revisions, nil
+}
+
+// Get returns the raw config stored in the given revision.
+func (h *ConfigHistory) Get(id string) ([]byte, error) {
+	path, err := h.revisionPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("revision not found")
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// prune removes revisions exceeding the configured count or age. The newest
+// revision is always kept so a rollback target exists.
+func (h *ConfigHistory) prune() {
+	revisions, err := h.list()
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Time{}
+	if h.maxAge > 0 {
+		cutoff = time.Now().Add(-h.maxAge)
+	}
+
+	for i, rev := range revisions {
+		if i == 0 {
+			continue
+		}
+
+		tooMany := h.maxRevisions > 0 && i >= h.maxRevisions
+		tooOld := !cutoff.IsZero() && rev.CreatedAt.Before(cutoff)
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		os.Remove(filepath.Join(h.dir, rev.ID+".json"))
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ConfigMigration upgrades a raw config document by one version. Migrations
+// work on the decoded JSON rather than models.AppConfig so they can read
+// shapes the current struct no longer accepts.
+type ConfigMigration struct {
+	Description string
+	Migrate     func(cfg map[string]any) error
+}
+
+// configMigrations is ordered; the migration at index i upgrades a config
+// from version i to version i+1.
+var configMigrations = []ConfigMigration{
+	{
+		Description: "convert itemPage.favoriteButton from a boolean to a list of item kinds",
+		Migrate:     migrateFavoriteButtonToKinds,
+	},
+}
+
+// CurrentConfigVersion is the version written by this backend.
+func CurrentConfigVersion() int {
+	return len(configMigrations)
+}
+
+func configVersion(cfg map[string]any) (int, error) {
+	raw, ok := cfg["configVersion"]
+	if !ok || raw == nil {
+		return 0, nil
+	}
+
+	version, ok := raw.(float64)
+	if !ok || version < 0 || version != float64(int(version)) {
+		return 0, errors.New("configVersion must be a non-negative integer")
+	}
+
+	return int(version), nil
+}
+
+// MigrateConfig runs all migrations newer than the config's configVersion
+// and returns the upgraded document along with the version it started at.
+// Configs that are already current are returned unchanged.
+func MigrateConfig(data []byte) ([]byte, int, error) {
+	var cfg map[string]any
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, 0, err
+	}
+	if cfg == nil {
+		cfg = map[string]any{}
+	}
+
+	from, err := configVersion(cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	current := CurrentConfigVersion()
+	if from > current {
+		return nil, from, fmt.Errorf("config version %d is newer than the supported version %d", from, current)
+	}
+	if from == current {
+		return data, from, nil
+	}
+
+	for version := from; version < current; version++ {
+		if err := configMigrations[version].Migrate(cfg); err != nil {
+			return nil, from, fmt.Errorf("migration to version %d (%s): %w", version+1, configMigrations[version].Description, err)
+		}
+	}
+	cfg["configVersion"] = current
+
+	migrated, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, from, err
+	}
+
+	return migrated, from, nil
+}
+
+// migrateFavoriteButtonToKinds upgrades configs from when the favorite
+// button was a single on/off switch.
+func migrateFavoriteButtonToKinds(cfg map[string]any) error {
+	itemPage, ok := cfg["itemPage"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	enabled, ok := itemPage["favoriteButton"].(bool)
+	if !ok {
+		return nil
+	}
+
+	if enabled {
+		itemPage["favoriteButton"] = []any{"Movie", "Series"}
+	} else {
+		itemPage["favoriteButton"] = []any{}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readMigrationFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "migrations", name))
+	if err != nil {
+		t.Fatalf("reading fixture %s: %v", name, err)
+	}
+	return data
+}
+
+func TestMigrateConfig(t *testing.T) {
+	tests := []struct {
+		fixture        string
+		fromVersion    int
+		favoriteButton any
+	}{
+		{"v0-favorite-button-true.json", 0, []any{"Movie", "Series"}},
+		{"v0-favorite-button-false.json", 0, []any{}},
+		{"v1-current.json", 1, []any{"Movie"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			migrated, from, err := MigrateConfig(readMigrationFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatalf("MigrateConfig: %v", err)
+			}
+			if from != tt.fromVersion {
+				t.Errorf("from version = %d, want %d", from, tt.fromVersion)
+			}
+
+			var cfg map[string]any
+			if err := json.Unmarshal(migrated, &cfg); err != nil {
+				t.Fatalf("decoding migrated config: %v", err)
+			}
+
+			if got := cfg["configVersion"]; got != float64(CurrentConfigVersion()) {
+				t.Errorf("configVersion = %v, want %d", got, CurrentConfigVersion())
+			}
+
+			got := cfg["itemPage"].(map[string]any)["favoriteButton"]
+			if !reflect.DeepEqual(got, tt.favoriteButton) {
+				t.Errorf("favoriteButton = %#v, want %#v", got, tt.favoriteButton)
+			}
+		})
+	}
+}
+
+func TestMigrateConfigRejectsNewerVersion(t *testing.T) {
+	data := []byte(`{"configVersion": 999}`)
+
+	if _, _, err := MigrateConfig(data); err == nil {
+		t.Fatal("expected an error for a config newer than the backend")
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// LockedOverlayPaths returns the locked JSON pointers that overlay would
+// change if it were merged into the global config.
+func LockedOverlayPaths(overlay []byte, locked []string) ([]string, error) {
+	var patch map[string]any
+	if err := json.Unmarshal(overlay, &patch); err != nil {
+		return nil, err
+	}
+
+	touched := []string{}
+	for _, path := range locked {
+		tokens, err := parsePointer(path)
+		if err != nil || len(tokens) == 0 {
+			continue
+		}
+		if stripLockedPath(patch, tokens) {
+			touched = append(touched, path)
+		}
+	}
+
+	return touched, nil
+}
+
+// ApplyOverlay merges a user's overlay into the global config, ignoring any
+// part of the overlay that targets a locked path.
+func ApplyOverlay(global, overlay []byte, locked []string) ([]byte, error) {
+	var patch any
+	if err := json.Unmarshal(overlay, &patch); err != nil {
+		return nil, err
+	}
+
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return nil, errors.New("overlay must be a JSON object")
+	}
+
+	for _, path := range locked {
+		tokens, err := parsePointer(path)
+		if err != nil || len(tokens) == 0 {
+			continue
+		}
+		stripLockedPath(patchObj, tokens)
+	}
+
+	stripped, err := json.Marshal(patchObj)
+	if err != nil {
+		return nil, err
+	}
+
+	return ApplyMergePatch(global, stripped)
+}
+
+// stripLockedPath removes whatever part of a merge patch would modify the
+// value at tokens and reports whether anything was removed.
+func stripLockedPath(patch map[string]any, tokens []string) bool {
+	value, ok := patch[tokens[0]]
+	if !ok {
+		return false
+	}
+
+	if len(tokens) == 1 {
+		delete(patch, tokens[0])
+		return true
+	}
+
+	child, isObject := value.(map[string]any)
+	if !isObject {
+		// A null or scalar here would replace the whole parent.
+		delete(patch, tokens[0])
+		return true
+	}
+
+	return stripLockedPath(child, tokens[1:])
+}
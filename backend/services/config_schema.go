@@ -0,0 +1,137 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"pelagica-backend/models"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// typeEnums lists the allowed values for the typed string aliases. The
+// slices are the same ones used by AppConfig.Validate.
+var typeEnums = map[reflect.Type]any{
+	reflect.TypeFor[models.BaseItemKind]():               models.BaseItemKinds,
+	reflect.TypeFor[models.ItemSortBy]():                 models.ItemSortBys,
+	reflect.TypeFor[models.DetailBadge]():                models.DetailBadges,
+	reflect.TypeFor[models.DetailField]():                models.DetailFields,
+	reflect.TypeFor[models.EpisodeDisplay]():             models.EpisodeDisplays,
+	reflect.TypeFor[models.ContinueWatchingDetailLine](): models.ContinueWatchingDetailLines,
+	reflect.TypeFor[models.ContinueWatchingTitleLine]():  models.ContinueWatchingTitleLines,
+	reflect.TypeFor[models.RecommendationTypeFilter]():   models.RecommendationTypeFilters,
+}
+
+// fieldOverrides adds constraints to plain fields that cannot be derived
+// from their Go type, keyed by "<StructName>.<jsonName>".
+var fieldOverrides = map[string]map[string]any{
+	"HomeScreenSection.type":       {"enum": models.SectionTypes},
+	"HomeScreenSection.size":       {"enum": models.MediaBarSizes},
+	"HomeScreenSection.limit":      {"minimum": 1},
+	"SectionItemsConfig.limit":     {"minimum": 1},
+	"SectionItemsConfig.sortOrder": {"enum": models.SortOrders},
+	"SectionItemsConfig.types":     {"items": map[string]any{"type": "string", "enum": models.BaseItemKinds}},
+}
+
+var configSchemaOnce = sync.OnceValue(func() map[string]any {
+	g := &schemaGenerator{definitions: map[string]any{}}
+
+	schema := g.structSchema(reflect.TypeFor[models.AppConfig]())
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = "Pelagica configuration"
+	schema["definitions"] = g.definitions
+
+	return schema
+})
+
+// ConfigSchema returns a JSON Schema describing models.AppConfig. It is
+// generated from the Go types by reflection, so it never drifts from them.
+func ConfigSchema() map[string]any {
+	return configSchemaOnce()
+}
+
+type schemaGenerator struct {
+	definitions map[string]any
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if values, ok := typeEnums[t]; ok {
+		return map[string]any{"type": "string", "enum": values}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := g.definitions[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate.
+			g.definitions[t.Name()] = nil
+			g.definitions[t.Name()] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/definitions/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := g.typeSchema(field.Type)
+		for key, value := range fieldOverrides[t.Name()+"."+name] {
+			prop[key] = value
+		}
+		properties[name] = prop
+
+		optional := strings.Contains(opts, "omitempty")
+		switch field.Type.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+			optional = true
+		}
+		if !optional {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CSSColorToHex converts the CSS colors themes are written in to #rrggbb,
+// which is what web app manifests can be relied on to understand. Hex
+// colors are returned unchanged; rgb() and oklch() are converted. ok is
+// false for anything else.
+func CSSColorToHex(value string) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if strings.HasPrefix(value, "#") {
+		switch len(value) {
+		case 4, 5, 7, 9:
+			if _, err := strconv.ParseUint(value[1:], 16, 32); err == nil {
+				return value, true
+			}
+		}
+		return "", false
+	}
+
+	name, args, ok := strings.Cut(value, "(")
+	if !ok || !strings.HasSuffix(args, ")") {
+		return "", false
+	}
+	// The alpha after a slash is dropped; manifests have no use for it.
+	args, _, _ = strings.Cut(strings.TrimSuffix(args, ")"), "/")
+	fields := strings.FieldsFunc(args, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) < 3 {
+		return "", false
+	}
+
+	switch name {
+	case "rgb", "rgba":
+		var rgb [3]float64
+		for i := range rgb {
+			channel, ok := parseCSSNumber(fields[i], 255)
+			if !ok {
+				return "", false
+			}
+			rgb[i] = channel / 255
+		}
+		return hexColor(rgb[0], rgb[1], rgb[2]), true
+	case "oklch":
+		lightness, okL := parseCSSNumber(fields[0], 1)
+		chroma, okC := parseCSSNumber(fields[1], 0.4)
+		hue, okH := parseCSSNumber(strings.TrimSuffix(fields[2], "deg"), 360)
+		if !okL || !okC || !okH {
+			return "", false
+		}
+		return oklchToHex(lightness, chroma, hue), true
+	}
+
+	return "", false
+}
+
+// parseCSSNumber parses a number or a percentage of percentOf. "none" is 0.
+func parseCSSNumber(value string, percentOf float64) (float64, bool) {
+	if value == "none" {
+		return 0, true
+	}
+	if strings.HasSuffix(value, "%") {
+		number, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		return number / 100 * percentOf, err == nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	return number, err == nil
+}
+
+// oklchToHex converts an OKLCH color to sRGB, clipping colors outside of
+// the sRGB gamut.
+func oklchToHex(lightness, chroma, hue float64) string {
+	a := chroma * math.Cos(hue*math.Pi/180)
+	b := chroma * math.Sin(hue*math.Pi/180)
+
+	l := math.Pow(lightness+0.3963377774*a+0.2158037573*b, 3)
+	m := math.Pow(lightness-0.1055613458*a-0.0638541728*b, 3)
+	s := math.Pow(lightness-0.0894841775*a-1.2914855480*b, 3)
+
+	return hexColor(
+		srgbGamma(4.0767416621*l-3.3077115913*m+0.2309699292*s),
+		srgbGamma(-1.2684380046*l+2.6097574011*m-0.3413193965*s),
+		srgbGamma(-0.0041960863*l-0.7034186147*m+1.7076147010*s),
+	)
+}
+
+func srgbGamma(linear float64) float64 {
+	if linear <= 0.0031308 {
+		return 12.92 * linear
+	}
+	return 1.055*math.Pow(linear, 1/2.4) - 0.055
+}
+
+func hexColor(r, g, b float64) string {
+	channel := func(value float64) int {
+		return int(math.Round(math.Max(0, math.Min(1, value)) * 255))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", channel(r), channel(g), channel(b))
+}
@@ -0,0 +1,29 @@
+package services
+
+import "testing"
+
+func TestCSSColorToHex(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"#181818", "#181818", true},
+		{"#FFF", "#fff", true},
+		{"rgb(255 128 0)", "#ff8000", true},
+		{"rgba(255, 0, 0, 0.5)", "#ff0000", true},
+		{"oklch(1 0 0)", "#ffffff", true},
+		{"oklch(0.145 0 0)", "#0a0a0a", true},
+		{"oklch(62.8% 0.2577 29.23deg / 50%)", "#ff0000", true},
+		{"oklch(0.205 0 none)", "#171717", true},
+		{"#12345", "", false},
+		{"hsl(0 100% 50%)", "", false},
+		{"var(--primary)", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := CSSColorToHex(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("CSSColorToHex(%q) = %q, %v; want %q, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
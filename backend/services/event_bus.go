@@ -0,0 +1,58 @@
+package services
+
+import (
+	"sync"
+
+	"pelagica-backend/models"
+)
+
+const eventSubscriberBuffer = 16
+
+// EventBus fans out events to all current subscribers. Publishing never
+// blocks: a subscriber whose buffer is full misses the event.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[chan models.Event]struct{}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan models.Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving all future events and a function
+// that must be called to stop receiving them.
+func (b *EventBus) Subscribe() (<-chan models.Event, func()) {
+	ch := make(chan models.Event, eventSubscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+func (b *EventBus) Publish(eventType string, data any) {
+	event := models.Event{Type: eventType, Data: data}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
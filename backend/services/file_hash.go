@@ -0,0 +1,62 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxFileHashes bounds the memo; it is simply reset when full.
+const maxFileHashes = 4096
+
+type fileHashEntry struct {
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+var fileHashes = struct {
+	mu      sync.Mutex
+	entries map[string]fileHashEntry
+}{entries: map[string]fileHashEntry{}}
+
+// FileHash returns the hex encoded SHA-256 of the file at path and its
+// modification time. Hashes are remembered until the file's size or
+// modification time changes, so serving a file does not rehash it.
+func FileHash(path string) (string, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	fileHashes.mu.Lock()
+	entry, ok := fileHashes.entries[path]
+	fileHashes.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.hash, info.ModTime(), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", time.Time{}, err
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	fileHashes.mu.Lock()
+	if len(fileHashes.entries) >= maxFileHashes {
+		fileHashes.entries = map[string]fileHashEntry{}
+	}
+	fileHashes.entries[path] = fileHashEntry{size: info.Size(), modTime: info.ModTime(), hash: hash}
+	fileHashes.mu.Unlock()
+
+	return hash, info.ModTime(), nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"pelagica-backend/persist"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FileWatcher calls registered callbacks when watched files or directories
+// change on disk. Bursts of events are debounced into a single callback.
+type FileWatcher struct {
+	fs       *fsnotify.Watcher
+	debounce time.Duration
+	mu       sync.Mutex
+	watches  []*fileWatch
+	done     chan struct{}
+}
+
+type fileWatch struct {
+	dir      string
+	name     string // empty to match every file in dir
+	onChange func()
+	timer    *time.Timer
+}
+
+func NewFileWatcher(debounce time.Duration) (*FileWatcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &FileWatcher{
+		fs:       fsWatcher,
+		debounce: debounce,
+		done:     make(chan struct{}),
+	}
+	go w.run()
+
+	return w, nil
+}
+
+// WatchFile calls onChange whenever path is created, written, renamed or
+// removed. The parent directory is watched so atomic replacements via
+// rename are picked up.
+func (w *FileWatcher) WatchFile(path string, onChange func()) error {
+	return w.add(filepath.Dir(path), filepath.Base(path), onChange)
+}
+
+// WatchDir calls onChange whenever any file directly inside dir changes.
+func (w *FileWatcher) WatchDir(dir string, onChange func()) error {
+	return w.add(dir, "", onChange)
+}
+
+func (w *FileWatcher) add(dir, name string, onChange func()) error {
+	dir = filepath.Clean(dir)
+	if err := w.fs.Add(dir); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.watches = append(w.watches, &fileWatch{dir: dir, name: name, onChange: onChange})
+	w.mu.Unlock()
+
+	return nil
+}
+
+func (w *FileWatcher) Close() error {
+	close(w.done)
+	return w.fs.Close()
+}
+
+func (w *FileWatcher) run() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			log.Println("File watcher error:", err)
+		}
+	}
+}
+
+func (w *FileWatcher) handle(event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod {
+		return
+	}
+
+	name := filepath.Base(event.Name)
+	if persist.IsTempFile(name) || strings.HasSuffix(name, persist.BackupSuffix) {
+		return
+	}
+	dir := filepath.Dir(event.Name)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, watch := range w.watches {
+		if watch.dir != dir || (watch.name != "" && watch.name != name) {
+			continue
+		}
+
+		if watch.timer != nil {
+			watch.timer.Stop()
+		}
+		watch.timer = time.AfterFunc(w.debounce, watch.onChange)
+	}
+}
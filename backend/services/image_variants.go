@@ -0,0 +1,227 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif" // Animated GIFs are rendered from their first frame.
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"pelagica-backend/persist"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	ImageFormatWebP = "webp"
+	ImageFormatPNG  = "png"
+	ImageFormatJPEG = "jpeg"
+
+	imageVariantInfix = ".variant-"
+	jpegQuality       = 85
+
+	// maxImageVariantSourcePixels keeps decoding of hostile images from
+	// exhausting memory.
+	maxImageVariantSourcePixels = 40_000_000
+
+	// maxConcurrentImageRenders bounds how many images are decoded and
+	// resized at once, as each can take hundreds of megabytes.
+	maxConcurrentImageRenders = 2
+)
+
+// ImageVariantSizes are the only sizes variants are rendered at. Requested
+// sizes are rounded up to the next step so a client cannot make the server
+// render and store an unbounded number of variants.
+var ImageVariantSizes = []int{32, 64, 96, 128, 192, 256, 384, 512, 768, 1024, 1536, 2048}
+
+var ImageFormatContentTypes = map[string]string{
+	ImageFormatWebP: "image/webp",
+	ImageFormatPNG:  "image/png",
+	ImageFormatJPEG: "image/jpeg",
+}
+
+// ErrUnsupportedImage is returned for sources that cannot be decoded, such
+// as SVG or ICO uploads. Callers serve the original instead.
+var ErrUnsupportedImage = errors.New("image format cannot be resized")
+
+var (
+	variantRenders singleflight.Group
+	renderSlots    = make(chan struct{}, maxConcurrentImageRenders)
+)
+
+// ImageVariant describes a resized and/or re-encoded copy of an image.
+// Width and Height of 0 leave that dimension to the aspect ratio; an empty
+// Format keeps the source format where it can be encoded.
+type ImageVariant struct {
+	Width  int
+	Height int
+	Format string
+}
+
+// IsOriginal reports whether the variant is the unmodified source.
+func (v ImageVariant) IsOriginal() bool {
+	return v.Width == 0 && v.Height == 0 && v.Format == ""
+}
+
+// BoundImageVariantSize rounds size up to the next allowed variant size,
+// capped at the largest.
+func BoundImageVariantSize(size int) int {
+	if size <= 0 {
+		return 0
+	}
+	for _, allowed := range ImageVariantSizes {
+		if size <= allowed {
+			return allowed
+		}
+	}
+	return ImageVariantSizes[len(ImageVariantSizes)-1]
+}
+
+func imageVariantPath(sourcePath string, variant ImageVariant, format string) string {
+	return sourcePath + imageVariantInfix + strconv.Itoa(variant.Width) + "x" + strconv.Itoa(variant.Height) + "." + format
+}
+
+// ImageVariantPattern matches every variant rendered from sourcePath.
+func ImageVariantPattern(sourcePath string) string {
+	return sourcePath + imageVariantInfix + "*"
+}
+
+// IsImageVariant reports whether name is a rendered variant file.
+func IsImageVariant(name string) bool {
+	return strings.Contains(filepath.Base(name), imageVariantInfix)
+}
+
+// RemoveImageVariants deletes the variants rendered from sourcePath, for
+// when the source is replaced or removed.
+func RemoveImageVariants(sourcePath string) {
+	paths, _ := filepath.Glob(ImageVariantPattern(sourcePath))
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+// ImageSize returns the dimensions of an encoded image, or
+// ErrUnsupportedImage if it cannot be resized.
+func ImageSize(data []byte) (int, int, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width*config.Height > maxImageVariantSourcePixels {
+		return 0, 0, ErrUnsupportedImage
+	}
+	return config.Width, config.Height, nil
+}
+
+// RenderImageVariant returns the path and content type of variant of the
+// image at sourcePath, rendering it next to the source unless an up to date
+// rendering is already there.
+func RenderImageVariant(sourcePath string, variant ImageVariant) (string, string, error) {
+	source, err := os.Stat(sourcePath)
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return "", "", err
+	}
+	config, sourceFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width*config.Height > maxImageVariantSourcePixels {
+		return "", "", ErrUnsupportedImage
+	}
+
+	format := variant.Format
+	if format == "" {
+		format = sourceFormat
+		if _, ok := ImageFormatContentTypes[format]; !ok {
+			format = ImageFormatPNG
+		}
+	}
+	contentType := ImageFormatContentTypes[format]
+
+	path := imageVariantPath(sourcePath, variant, format)
+	if rendered, err := os.Stat(path); err == nil && !rendered.ModTime().Before(source.ModTime()) {
+		return path, contentType, nil
+	}
+
+	_, err, _ = variantRenders.Do(path, func() (interface{}, error) {
+		renderSlots <- struct{}{}
+		defer func() { <-renderSlots }()
+
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedImage
+		}
+
+		width, height := fitImageSize(config.Width, config.Height, variant.Width, variant.Height)
+		if width != config.Width || height != config.Height {
+			img = resizeImage(img, width, height)
+		}
+
+		encoded, err := encodeImage(img, format)
+		if err != nil {
+			return nil, err
+		}
+		return nil, persist.WriteFile(path, encoded, 0644)
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return path, contentType, nil
+}
+
+// fitImageSize scales width x height to fit within maxWidth x maxHeight,
+// keeping the aspect ratio. Images are never scaled up.
+func fitImageSize(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight {
+		scale = min(scale, float64(maxHeight)/float64(height))
+	}
+	if scale == 1 {
+		return width, height
+	}
+	return max(1, int(float64(width)*scale+0.5)), max(1, int(float64(height)*scale+0.5))
+}
+
+func resizeImage(img image.Image, width, height int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+func encodeImage(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+
+	switch format {
+	case ImageFormatWebP:
+		err = nativewebp.Encode(&buf, img, nil)
+	case ImageFormatPNG:
+		err = png.Encode(&buf, img)
+	case ImageFormatJPEG:
+		// JPEG has no alpha channel, so transparent logos are put on white
+		// rather than the black they would otherwise end up on.
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		err = jpeg.Encode(&buf, flat, &jpeg.Options{Quality: jpegQuality})
+	default:
+		err = errors.New("unsupported image format: " + format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestPNG(t *testing.T, path string, width, height int) {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderImageVariant(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "logo-dark")
+	writeTestPNG(t, source, 400, 200)
+
+	tests := []struct {
+		variant     ImageVariant
+		contentType string
+		format      string
+		width       int
+		height      int
+	}{
+		{ImageVariant{Width: BoundImageVariantSize(100)}, "image/png", "png", 128, 64},
+		{ImageVariant{Height: 50, Format: ImageFormatWebP}, "image/webp", "webp", 100, 50},
+		{ImageVariant{Width: 64, Height: 64, Format: ImageFormatJPEG}, "image/jpeg", "jpeg", 64, 32},
+		{ImageVariant{Width: 2048, Format: ImageFormatWebP}, "image/webp", "webp", 400, 200},
+	}
+
+	for _, tt := range tests {
+		path, contentType, err := RenderImageVariant(source, tt.variant)
+		if err != nil {
+			t.Fatalf("RenderImageVariant(%+v): %v", tt.variant, err)
+		}
+		if contentType != tt.contentType {
+			t.Errorf("%+v: content type = %s, want %s", tt.variant, contentType, tt.contentType)
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, format, err := image.DecodeConfig(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("%+v: decoding variant: %v", tt.variant, err)
+		}
+		if format != tt.format || config.Width != tt.width || config.Height != tt.height {
+			t.Errorf("%+v: got %s %dx%d, want %s %dx%d", tt.variant, format, config.Width, config.Height, tt.format, tt.width, tt.height)
+		}
+	}
+
+	RemoveImageVariants(source)
+	if variants, _ := filepath.Glob(ImageVariantPattern(source)); len(variants) != 0 {
+		t.Errorf("variants left after removal: %v", variants)
+	}
+	if _, err := os.Stat(source); err != nil {
+		t.Errorf("source was removed with its variants: %v", err)
+	}
+}
+
+func TestRenderImageVariantUnsupportedSource(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "logo-light")
+	if err := os.WriteFile(source, []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := RenderImageVariant(source, ImageVariant{Width: 64}); !errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("err = %v, want ErrUnsupportedImage", err)
+	}
+}
+
+func TestBoundImageVariantSize(t *testing.T) {
+	for size, want := range map[int]int{0: 0, 1: 32, 32: 32, 33: 64, 500: 512, 5000: 2048} {
+		if got := BoundImageVariantSize(size); got != want {
+			t.Errorf("BoundImageVariantSize(%d) = %d, want %d", size, got, want)
+		}
+	}
+}
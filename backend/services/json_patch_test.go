@@ -0,0 +1,85 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	const doc = `{"a":{"b":[1,2,3]},"c":"x","m~n":1,"p/q":2}`
+
+	tests := []struct {
+		name  string
+		patch string
+		want  string // empty when the patch must fail
+	}{
+		{"add member", `[{"op":"add","path":"/d","value":4}]`, `{"a":{"b":[1,2,3]},"c":"x","d":4,"m~n":1,"p/q":2}`},
+		{"add replaces member", `[{"op":"add","path":"/c","value":"y"}]`, `{"a":{"b":[1,2,3]},"c":"y","m~n":1,"p/q":2}`},
+		{"add inserts into array", `[{"op":"add","path":"/a/b/1","value":9}]`, `{"a":{"b":[1,9,2,3]},"c":"x","m~n":1,"p/q":2}`},
+		{"add appends with dash", `[{"op":"add","path":"/a/b/-","value":9}]`, `{"a":{"b":[1,2,3,9]},"c":"x","m~n":1,"p/q":2}`},
+		{"add at array length", `[{"op":"add","path":"/a/b/3","value":9}]`, `{"a":{"b":[1,2,3,9]},"c":"x","m~n":1,"p/q":2}`},
+		{"add past array length", `[{"op":"add","path":"/a/b/4","value":9}]`, ""},
+		{"add to missing parent", `[{"op":"add","path":"/x/y","value":1}]`, ""},
+		{"add null", `[{"op":"add","path":"/d","value":null}]`, `{"a":{"b":[1,2,3]},"c":"x","d":null,"m~n":1,"p/q":2}`},
+		{"add without value", `[{"op":"add","path":"/d"}]`, ""},
+		{"add root", `[{"op":"add","path":"","value":{"z":1}}]`, `{"z":1}`},
+		{"remove member", `[{"op":"remove","path":"/c"}]`, `{"a":{"b":[1,2,3]},"m~n":1,"p/q":2}`},
+		{"remove array element", `[{"op":"remove","path":"/a/b/0"}]`, `{"a":{"b":[2,3]},"c":"x","m~n":1,"p/q":2}`},
+		{"remove out of range", `[{"op":"remove","path":"/a/b/3"}]`, ""},
+		{"remove with dash", `[{"op":"remove","path":"/a/b/-"}]`, ""},
+		{"remove leading zero index", `[{"op":"remove","path":"/a/b/01"}]`, ""},
+		{"remove missing member", `[{"op":"remove","path":"/missing"}]`, ""},
+		{"replace member", `[{"op":"replace","path":"/c","value":[1]}]`, `{"a":{"b":[1,2,3]},"c":[1],"m~n":1,"p/q":2}`},
+		{"replace array element", `[{"op":"replace","path":"/a/b/2","value":0}]`, `{"a":{"b":[1,2,0]},"c":"x","m~n":1,"p/q":2}`},
+		{"replace with null", `[{"op":"replace","path":"/c","value":null}]`, `{"a":{"b":[1,2,3]},"c":null,"m~n":1,"p/q":2}`},
+		{"replace without value", `[{"op":"replace","path":"/c"}]`, ""},
+		{"replace missing member", `[{"op":"replace","path":"/missing","value":1}]`, ""},
+		{"move member", `[{"op":"move","from":"/c","path":"/a/c"}]`, `{"a":{"b":[1,2,3],"c":"x"},"m~n":1,"p/q":2}`},
+		{"move array element", `[{"op":"move","from":"/a/b/0","path":"/a/b/-"}]`, `{"a":{"b":[2,3,1]},"c":"x","m~n":1,"p/q":2}`},
+		{"move into own child", `[{"op":"move","from":"/a","path":"/a/b/0"}]`, ""},
+		{"copy member", `[{"op":"copy","from":"/a/b","path":"/e"}]`, `{"a":{"b":[1,2,3]},"c":"x","e":[1,2,3],"m~n":1,"p/q":2}`},
+		{"copy is deep", `[{"op":"copy","from":"/a","path":"/e"},{"op":"add","path":"/e/b/-","value":4}]`, `{"a":{"b":[1,2,3]},"c":"x","e":{"b":[1,2,3,4]},"m~n":1,"p/q":2}`},
+		{"test passes", `[{"op":"test","path":"/a/b","value":[1,2,3]},{"op":"remove","path":"/c"}]`, `{"a":{"b":[1,2,3]},"m~n":1,"p/q":2}`},
+		{"test fails", `[{"op":"remove","path":"/c"},{"op":"test","path":"/a/b/0","value":2}]`, ""},
+		{"test null passes", `[{"op":"add","path":"/d","value":null},{"op":"test","path":"/d","value":null}]`, `{"a":{"b":[1,2,3]},"c":"x","d":null,"m~n":1,"p/q":2}`},
+		{"test null fails", `[{"op":"test","path":"/c","value":null}]`, ""},
+		{"test without value", `[{"op":"test","path":"/c"}]`, ""},
+		{"tilde escape", `[{"op":"replace","path":"/m~0n","value":5}]`, `{"a":{"b":[1,2,3]},"c":"x","m~n":5,"p/q":2}`},
+		{"slash escape", `[{"op":"remove","path":"/p~1q"}]`, `{"a":{"b":[1,2,3]},"c":"x","m~n":1}`},
+		{"path without slash", `[{"op":"remove","path":"c"}]`, ""},
+		{"unknown operation", `[{"op":"rename","path":"/c"}]`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyJSONPatch([]byte(doc), []byte(tt.patch))
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("patch succeeded with %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyJSONPatch: %v", err)
+			}
+
+			var gotValue, wantValue any
+			json.Unmarshal(got, &gotValue)
+			json.Unmarshal([]byte(tt.want), &wantValue)
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	got, err := ApplyMergePatch([]byte(`{"a":{"b":1,"c":2},"d":[1]}`), []byte(`{"a":{"b":null,"e":3},"d":[2]}`))
+	if err != nil {
+		t.Fatalf("ApplyMergePatch: %v", err)
+	}
+	if want := `{"a":{"c":2,"e":3},"d":[2]}`; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
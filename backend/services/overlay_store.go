@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"pelagica-backend/models"
+	"pelagica-backend/persist"
+)
+
+// OverlayStore keeps one config overlay per key, e.g. per Jellyfin user or
+// per server. An overlay is a JSON Merge Patch that is applied on top of the
+// global config.
+type OverlayStore struct {
+	dir     string
+	validID func(string) bool
+	mu      sync.RWMutex
+}
+
+// NewOverlayStore stores overlays as <dir>/<id>.json. validID must only
+// accept IDs that are safe to use as file names.
+func NewOverlayStore(dir string, validID func(string) bool) (*OverlayStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &OverlayStore{dir: dir, validID: validID}, nil
+}
+
+// NewUserOverlayStore stores user overlays under the keys returned by
+// UserOverlayKey.
+func NewUserOverlayStore(dir string) (*OverlayStore, error) {
+	return NewOverlayStore(dir, isUserOverlayKey)
+}
+
+// UserOverlayKey returns the key of the overlay of userID on serverID. Users
+// of the server configured outside the registry keep their plain user ID.
+func UserOverlayKey(serverID, userID string) string {
+	if serverID == "" {
+		return userID
+	}
+	return serverID + "_" + userID
+}
+
+func isUserOverlayKey(key string) bool {
+	serverID, userID, scoped := strings.Cut(key, "_")
+	if !scoped {
+		return models.IsJellyfinUserID(key)
+	}
+	return models.IsServerID(serverID) && models.IsJellyfinUserID(userID)
+}
+
+func (s *OverlayStore) path(id string) (string, error) {
+	if !s.validID(id) {
+		return "", errors.New("invalid overlay id")
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// Get returns the overlay for id, or nil if there is none.
+func (s *OverlayStore) Get(id string) ([]byte, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *OverlayStore) Write(id string, data []byte) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return persist.WriteFile(path, data, 0644)
+}
+
+func (s *OverlayStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"pelagica-backend/models"
+	"pelagica-backend/persist"
+)
+
+// RoleStore persists delegated role assignments in a single JSON file.
+type RoleStore struct {
+	path        string
+	assignments models.RoleAssignments
+	mu          sync.RWMutex
+}
+
+func NewRoleStore(path string) (*RoleStore, error) {
+	store := &RoleStore{path: path}
+
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Reload re-reads the roles file. A missing file means no delegated roles.
+func (s *RoleStore) Reload() error {
+	assignments := models.RoleAssignments{}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil && len(data) > 0 {
+		if err := json.Unmarshal(data, &assignments); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.assignments = assignments
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *RoleStore) Path() string {
+	return s.path
+}
+
+func (s *RoleStore) Get() models.RoleAssignments {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.assignments
+}
+
+func (s *RoleStore) Write(assignments models.RoleAssignments) error {
+	data, err := json.MarshalIndent(assignments, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := persist.WriteFileWithBackup(s.path, data, 0644); err != nil {
+		return err
+	}
+	s.assignments = assignments
+
+	return nil
+}
+
+func (s *RoleStore) PermissionsFor(serverID, userID string) []models.Permission {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.assignments.PermissionsFor(serverID, userID)
+}
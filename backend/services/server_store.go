@@ -0,0 +1,78 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"pelagica-backend/models"
+	"pelagica-backend/persist"
+)
+
+// ServerStore persists the registry of named Jellyfin servers in a single
+// JSON file.
+type ServerStore struct {
+	path     string
+	registry models.ServerRegistry
+	mu       sync.RWMutex
+}
+
+func NewServerStore(path string) (*ServerStore, error) {
+	store := &ServerStore{path: path}
+
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Reload re-reads the servers file. A missing file means a single-server
+// setup.
+func (s *ServerStore) Reload() error {
+	registry := models.ServerRegistry{}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil && len(data) > 0 {
+		if err := json.Unmarshal(data, &registry); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.registry = registry
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *ServerStore) Path() string {
+	return s.path
+}
+
+func (s *ServerStore) Get() models.ServerRegistry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.registry
+}
+
+func (s *ServerStore) Write(registry models.ServerRegistry) error {
+	data, err := json.MarshalIndent(registry, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := persist.WriteFileWithBackup(s.path, data, 0644); err != nil {
+		return err
+	}
+	s.registry = registry
+
+	return nil
+}
@@ -0,0 +1,660 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pelagica-backend/jellyfin"
+	"pelagica-backend/models"
+	"pelagica-backend/persist"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// studioIndexFormat is bumped whenever indexedItem changes; indexes in
+	// another format, including those from before the index held more than
+	// studios, are rebuilt.
+	studioIndexFormat = 2
+
+	// incrementalOverlap widens the MinDateLastSaved window so items saved
+	// while the previous build was running are not missed.
+	incrementalOverlap = time.Minute
+
+	// maxCachedFacets bounds the facets cached per index, as roles and
+	// combinations of filters are up to the client; the cache is simply
+	// reset when full.
+	maxCachedFacets = 256
+)
+
+// indexedCollectionTypes are the library types whose items are indexed.
+// Mixed libraries have no collection type.
+var indexedCollectionTypes = []string{"movies", "tvshows", ""}
+
+// StudioIndexKey identifies one index. Each user gets their own index
+// because library access differs between users.
+type StudioIndexKey struct {
+	ServerID    string
+	JellyfinURL string
+	UserID      string
+}
+
+func (k StudioIndexKey) fileName() string {
+	sum := sha256.Sum256([]byte(k.ServerID + "\n" + k.JellyfinURL + "\n" + k.UserID))
+	return hex.EncodeToString(sum[:16]) + ".json"
+}
+
+// indexedValue is a genre, studio or person of an indexed item. Type is
+// only set for people, whose facet can be filtered by it.
+type indexedValue struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// indexedItem holds the fields of a Movie or Series that facets are
+// counted over, and nothing else; an index has an entry for every item the
+// user can see.
+type indexedItem struct {
+	Type           string         `json:"type"`
+	LibraryID      string         `json:"libraryId"`
+	ProductionYear int            `json:"productionYear,omitempty"`
+	OfficialRating string         `json:"officialRating,omitempty"`
+	Genres         []indexedValue `json:"genres,omitempty"`
+	Tags           []string       `json:"tags,omitempty"`
+	Studios        []indexedValue `json:"studios,omitempty"`
+	People         []indexedValue `json:"people,omitempty"`
+}
+
+func newIndexedItem(item jellyfin.Item, libraryID string) indexedItem {
+	indexed := indexedItem{
+		Type:           item.Type,
+		LibraryID:      libraryID,
+		ProductionYear: item.ProductionYear,
+		OfficialRating: item.OfficialRating,
+		Tags:           item.Tags,
+	}
+
+	for _, genre := range item.GenreItems {
+		indexed.Genres = append(indexed.Genres, indexedValue{ID: genre.ID, Name: genre.Name})
+	}
+	if len(item.GenreItems) == 0 {
+		for _, genre := range item.Genres {
+			indexed.Genres = append(indexed.Genres, indexedValue{Name: genre})
+		}
+	}
+	for _, studio := range item.Studios {
+		indexed.Studios = append(indexed.Studios, indexedValue{ID: studio.ID, Name: studio.Name})
+	}
+	for _, person := range item.People {
+		indexed.People = append(indexed.People, indexedValue{ID: person.ID, Name: person.Name, Type: person.Type})
+	}
+
+	return indexed
+}
+
+// internItems makes items share one copy of each string. The same genres,
+// studios, people and library IDs come up on many items, and decoding an
+// index or a page of items allocates each occurrence separately.
+func internItems(items map[string]indexedItem) {
+	seen := map[string]string{}
+	intern := func(s string) string {
+		if existing, ok := seen[s]; ok {
+			return existing
+		}
+		seen[s] = s
+		return s
+	}
+	internValues := func(values []indexedValue) {
+		for i := range values {
+			values[i].ID = intern(values[i].ID)
+			values[i].Name = intern(values[i].Name)
+			values[i].Type = intern(values[i].Type)
+		}
+	}
+
+	for id, item := range items {
+		item.Type = intern(item.Type)
+		item.LibraryID = intern(item.LibraryID)
+		item.OfficialRating = intern(item.OfficialRating)
+		for i := range item.Tags {
+			item.Tags[i] = intern(item.Tags[i])
+		}
+		internValues(item.Genres)
+		internValues(item.Studios)
+		internValues(item.People)
+		items[id] = item
+	}
+}
+
+// studioIndexFile is the on-disk form of an index, keyed by item ID so
+// incremental builds can replace the entries of changed items.
+type studioIndexFile struct {
+	Format            int                    `json:"format"`
+	ServerID          string                 `json:"serverId,omitempty"`
+	JellyfinURL       string                 `json:"jellyfinUrl"`
+	UserID            string                 `json:"userId"`
+	Libraries         []string               `json:"libraries"`
+	Items             map[string]indexedItem `json:"items"`
+	SyncedFrom        time.Time              `json:"syncedFrom"`
+	LastBuildAt       time.Time              `json:"lastBuildAt"`
+	LastBuildDuration time.Duration          `json:"lastBuildDuration"`
+	LastBuildType     string                 `json:"lastBuildType"`
+	LastFullBuildAt   time.Time              `json:"lastFullBuildAt"`
+}
+
+type studioIndexEntry struct {
+	key  StudioIndexKey
+	data *studioIndexFile
+
+	// facets caches computed facets until the next build.
+	facets map[string][]models.FacetValue
+
+	// token is only kept in memory; after a restart an index is served from
+	// disk and refreshed again once its user makes a request.
+	token     string
+	lastError string
+	building  bool
+}
+
+// StudioIndex keeps the studios of every Movie and Series per server and
+// user, along with the other fields facets are counted over. It is
+// refreshed in the background and persisted, so requests never wait for a
+// crawl of the whole library once an index exists. Indexes not rebuilt for
+// maxAge, because their user is gone or refreshes keep failing, are
+// dropped rather than served.
+type StudioIndex struct {
+	dir                 string
+	fullRebuildInterval time.Duration
+	maxAge              time.Duration
+	entries             map[string]*studioIndexEntry
+	builds              singleflight.Group
+	mu                  sync.Mutex
+}
+
+// NewStudioIndex loads the indexes persisted in dir. A maxAge of 0 keeps
+// indexes however old they get.
+func NewStudioIndex(dir string, fullRebuildInterval, maxAge time.Duration) (*StudioIndex, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	index := &StudioIndex{
+		dir:                 dir,
+		fullRebuildInterval: fullRebuildInterval,
+		maxAge:              maxAge,
+		entries:             map[string]*studioIndexEntry{},
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("studio index: skipping unreadable index %s: %v", path, err)
+			continue
+		}
+
+		var file studioIndexFile
+		if err := json.Unmarshal(data, &file); err != nil || file.Format != studioIndexFormat {
+			log.Printf("studio index: skipping outdated or invalid index %s", path)
+			continue
+		}
+		if index.isStale(&file) {
+			log.Printf("studio index: removing index %s last built at %s", path, file.LastBuildAt.Format(time.RFC3339))
+			os.Remove(path)
+			continue
+		}
+		internItems(file.Items)
+
+		key := StudioIndexKey{ServerID: file.ServerID, JellyfinURL: file.JellyfinURL, UserID: file.UserID}
+		index.entries[key.fileName()] = &studioIndexEntry{key: key, data: &file}
+	}
+
+	return index, nil
+}
+
+func (x *StudioIndex) isStale(data *studioIndexFile) bool {
+	return x.maxAge > 0 && time.Since(data.LastBuildAt) > x.maxAge
+}
+
+// dropStaleLocked forgets the data of entry if it is older than maxAge, so
+// the next request rebuilds it in full. It must be called with x.mu held.
+func (x *StudioIndex) dropStaleLocked(entry *studioIndexEntry) {
+	if entry.data == nil || !x.isStale(entry.data) {
+		return
+	}
+
+	log.Printf("studio index: dropping index for user %s last built at %s", entry.key.UserID, entry.data.LastBuildAt.Format(time.RFC3339))
+	entry.data = nil
+	entry.facets = nil
+	os.Remove(filepath.Join(x.dir, entry.key.fileName()))
+}
+
+func (x *StudioIndex) entry(key StudioIndexKey) *studioIndexEntry {
+	name := key.fileName()
+
+	entry, ok := x.entries[name]
+	if !ok {
+		entry = &studioIndexEntry{key: key}
+		x.entries[name] = entry
+	}
+	return entry
+}
+
+// Facet counts kind over the indexed items matching filter, building the
+// index first if there is none yet. token is remembered for background
+// refreshes. Values are sorted by count, most used first.
+func (x *StudioIndex) Facet(key StudioIndexKey, token string, kind models.FacetKind, filter models.FacetFilter) ([]models.FacetValue, error) {
+	x.mu.Lock()
+	entry := x.entry(key)
+	entry.token = token
+	x.dropStaleLocked(entry)
+	built := entry.data != nil
+	x.mu.Unlock()
+
+	if !built {
+		if err := x.Build(key); err != nil {
+			return nil, err
+		}
+	}
+
+	x.mu.Lock()
+	data := entry.data
+	x.mu.Unlock()
+
+	// Libraries the index does not have match nothing, so they are left out
+	// of the filter and cannot grow the facet cache.
+	if len(filter.LibraryIDs) > 0 {
+		filter.LibraryIDs = slices.DeleteFunc(slices.Clone(filter.LibraryIDs), func(id string) bool {
+			return !slices.Contains(data.Libraries, id)
+		})
+		if len(filter.LibraryIDs) == 0 {
+			return []models.FacetValue{}, nil
+		}
+	}
+
+	cacheKey := facetCacheKey(kind, filter)
+
+	x.mu.Lock()
+	if values, ok := entry.facets[cacheKey]; ok {
+		x.mu.Unlock()
+		return values, nil
+	}
+	x.mu.Unlock()
+
+	values := countFacet(data.Items, kind, filter)
+
+	x.mu.Lock()
+	if entry.data == data {
+		if entry.facets == nil || len(entry.facets) >= maxCachedFacets {
+			entry.facets = map[string][]models.FacetValue{}
+		}
+		entry.facets[cacheKey] = values
+	}
+	x.mu.Unlock()
+
+	return values, nil
+}
+
+// Studios returns the studios facet over all items in the shape the studios
+// endpoint has always served.
+func (x *StudioIndex) Studios(key StudioIndexKey, token string) ([]models.StudioSummary, error) {
+	values, err := x.Facet(key, token, models.FacetStudios, models.FacetFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	studios := make([]models.StudioSummary, 0, len(values))
+	for _, value := range values {
+		studios = append(studios, models.StudioSummary{ID: value.ID, Name: value.Name, Count: value.Count})
+	}
+	return studios, nil
+}
+
+func facetCacheKey(kind models.FacetKind, filter models.FacetFilter) string {
+	libraries := slices.Compact(slices.Sorted(slices.Values(filter.LibraryIDs)))
+	types := slices.Compact(slices.Sorted(slices.Values(filter.ItemTypes)))
+	return string(kind) + "|" + strings.Join(libraries, ",") + "|" + strings.Join(types, ",") + "|" + strings.ToLower(filter.Role)
+}
+
+// RefreshAll rebuilds every index that has a token, incrementally unless a
+// full rebuild is due, then drops the indexes that are still older than
+// maxAge.
+func (x *StudioIndex) RefreshAll() {
+	x.mu.Lock()
+	keys := []StudioIndexKey{}
+	for _, entry := range x.entries {
+		if entry.token != "" {
+			keys = append(keys, entry.key)
+		}
+	}
+	x.mu.Unlock()
+
+	for _, key := range keys {
+		if err := x.Build(key); err != nil {
+			log.Printf("studio index: refreshing index for user %s failed: %v", key.UserID, err)
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for name, entry := range x.entries {
+		x.dropStaleLocked(entry)
+		if entry.data == nil && entry.token == "" && !entry.building {
+			delete(x.entries, name)
+		}
+	}
+}
+
+// Build refreshes the index for key. Concurrent builds of the same index
+// are collapsed into one.
+func (x *StudioIndex) Build(key StudioIndexKey) error {
+	_, err, _ := x.builds.Do(key.fileName(), func() (interface{}, error) {
+		return nil, x.build(key)
+	})
+	return err
+}
+
+func (x *StudioIndex) build(key StudioIndexKey) error {
+	x.mu.Lock()
+	entry := x.entry(key)
+	token := entry.token
+	previous := entry.data
+	entry.building = true
+	x.mu.Unlock()
+
+	defer func() {
+		x.mu.Lock()
+		entry.building = false
+		x.mu.Unlock()
+	}()
+
+	if token == "" {
+		return errors.New("no token available to build the studio index")
+	}
+
+	started := time.Now().UTC()
+	full := previous == nil || started.Sub(previous.LastFullBuildAt) >= x.fullRebuildInterval
+
+	libraries, items, err := x.crawl(key, token, previous, full)
+	if err != nil {
+		x.mu.Lock()
+		entry.lastError = err.Error()
+		if jellyfin.IsUnauthorized(err) {
+			entry.token = ""
+		}
+		x.mu.Unlock()
+		return err
+	}
+
+	internItems(items)
+
+	data := &studioIndexFile{
+		Format:            studioIndexFormat,
+		ServerID:          key.ServerID,
+		JellyfinURL:       key.JellyfinURL,
+		UserID:            key.UserID,
+		Libraries:         libraries,
+		Items:             items,
+		SyncedFrom:        started,
+		LastBuildAt:       time.Now().UTC(),
+		LastBuildDuration: time.Since(started),
+		LastBuildType:     models.StudioIndexBuildIncremental,
+	}
+	if full {
+		data.LastBuildType = models.StudioIndexBuildFull
+		data.LastFullBuildAt = started
+	} else {
+		data.LastFullBuildAt = previous.LastFullBuildAt
+	}
+
+	encoded, err := json.Marshal(data)
+	if err == nil {
+		err = persist.WriteFile(filepath.Join(x.dir, key.fileName()), encoded, 0600)
+	}
+	if err != nil {
+		log.Printf("studio index: failed to persist index: %v", err)
+	}
+
+	x.mu.Lock()
+	entry.data = data
+	entry.facets = nil
+	entry.lastError = ""
+	x.mu.Unlock()
+
+	log.Printf("studio index: %s build for user %s took %s (%d libraries, %d items)",
+		data.LastBuildType, key.UserID, data.LastBuildDuration.Round(time.Millisecond), len(libraries), len(items))
+
+	return nil
+}
+
+// crawl pages through the items of every movie, show and mixed library.
+// Incremental crawls only fetch items saved since the previous build and
+// patch them into a copy of its items; removed items and libraries are only
+// dropped by the next full crawl.
+func (x *StudioIndex) crawl(key StudioIndexKey, token string, previous *studioIndexFile, full bool) ([]string, map[string]indexedItem, error) {
+	client, err := jellyfin.NewClient(key.JellyfinURL, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	views, err := client.Views(ctx, key.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := map[string]indexedItem{}
+	var since string
+	if !full {
+		for id, item := range previous.Items {
+			items[id] = item
+		}
+		since = previous.SyncedFrom.Add(-incrementalOverlap).Format(time.RFC3339)
+	}
+
+	libraries := []string{}
+	for _, view := range views {
+		if !slices.Contains(indexedCollectionTypes, view.CollectionType) {
+			continue
+		}
+		libraries = append(libraries, view.ID)
+
+		query := jellyfin.NewItemsQuery().
+			ParentID(view.ID).
+			Recursive().
+			IncludeItemTypes(models.FacetItemTypes...).
+			Fields("Studios", "Genres", "Tags", "People", "OfficialRating").
+			EnableImages(false)
+		if since != "" {
+			query.MinDateLastSaved(since)
+		}
+
+		err := client.EachItemsPage(ctx, key.UserID, query, jellyfin.DefaultPageSize, func(page []jellyfin.Item) error {
+			for _, item := range page {
+				items[item.ID] = newIndexedItem(item, view.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return libraries, items, nil
+}
+
+// Status reports every known index, ordered by server and user.
+func (x *StudioIndex) Status() []models.StudioIndexStatus {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	statuses := make([]models.StudioIndexStatus, 0, len(x.entries))
+	for _, entry := range x.entries {
+		status := models.StudioIndexStatus{
+			ServerID:    entry.key.ServerID,
+			JellyfinURL: entry.key.JellyfinURL,
+			UserID:      entry.key.UserID,
+			LastError:   entry.lastError,
+			Building:    entry.building,
+			Scheduled:   entry.token != "",
+		}
+		if data := entry.data; data != nil {
+			lastBuildAt, lastFullBuildAt := data.LastBuildAt, data.LastFullBuildAt
+			status.Libraries = len(data.Libraries)
+			status.Items = len(data.Items)
+			status.Studios = len(countFacet(data.Items, models.FacetStudios, models.FacetFilter{}))
+			status.LastBuildAt = &lastBuildAt
+			status.LastBuildDurationMs = data.LastBuildDuration.Milliseconds()
+			status.LastBuildType = data.LastBuildType
+			status.LastFullBuildAt = &lastFullBuildAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].ServerID != statuses[j].ServerID {
+			return statuses[i].ServerID < statuses[j].ServerID
+		}
+		if statuses[i].JellyfinURL != statuses[j].JellyfinURL {
+			return statuses[i].JellyfinURL < statuses[j].JellyfinURL
+		}
+		return statuses[i].UserID < statuses[j].UserID
+	})
+
+	return statuses
+}
+
+// facetValues extracts the values of kind from one item. Each value is
+// counted at most once per item.
+func facetValues(item indexedItem, kind models.FacetKind, role string) []models.FacetValue {
+	values := []models.FacetValue{}
+
+	switch kind {
+	case models.FacetStudios:
+		for _, studio := range item.Studios {
+			values = append(values, models.FacetValue{ID: studio.ID, Name: studio.Name})
+		}
+	case models.FacetNetworks:
+		if item.Type == "Series" {
+			for _, studio := range item.Studios {
+				values = append(values, models.FacetValue{ID: studio.ID, Name: studio.Name})
+			}
+		}
+	case models.FacetGenres:
+		for _, genre := range item.Genres {
+			values = append(values, models.FacetValue{ID: genre.ID, Name: genre.Name})
+		}
+	case models.FacetTags:
+		for _, tag := range item.Tags {
+			values = append(values, models.FacetValue{Name: tag})
+		}
+	case models.FacetYears:
+		if item.ProductionYear > 0 {
+			year := strconv.Itoa(item.ProductionYear)
+			values = append(values, models.FacetValue{ID: year, Name: year})
+		}
+	case models.FacetDecades:
+		if item.ProductionYear > 0 {
+			decade := strconv.Itoa(item.ProductionYear / 10 * 10)
+			values = append(values, models.FacetValue{ID: decade, Name: decade + "s"})
+		}
+	case models.FacetRatings:
+		if item.OfficialRating != "" {
+			values = append(values, models.FacetValue{Name: item.OfficialRating})
+		}
+	case models.FacetPeople:
+		for _, person := range item.People {
+			if role == "" || strings.EqualFold(person.Type, role) {
+				values = append(values, models.FacetValue{ID: person.ID, Name: person.Name})
+			}
+		}
+	}
+
+	return values
+}
+
+func countFacet(items map[string]indexedItem, kind models.FacetKind, filter models.FacetFilter) []models.FacetValue {
+	counts := map[string]*models.FacetValue{}
+
+	for _, item := range items {
+		if len(filter.LibraryIDs) > 0 && !slices.Contains(filter.LibraryIDs, item.LibraryID) {
+			continue
+		}
+		if len(filter.ItemTypes) > 0 && !slices.Contains(filter.ItemTypes, item.Type) {
+			continue
+		}
+
+		seen := map[string]bool{}
+		for _, value := range facetValues(item, kind, filter.Role) {
+			if value.Name == "" {
+				continue
+			}
+
+			// Values without an ID, like tags, are matched by name.
+			valueKey := value.ID
+			if valueKey == "" {
+				valueKey = strings.ToLower(value.Name)
+			}
+			if seen[valueKey] {
+				continue
+			}
+			seen[valueKey] = true
+
+			if existing := counts[valueKey]; existing != nil {
+				existing.Count++
+				continue
+			}
+			value.Count = 1
+			counts[valueKey] = &value
+		}
+	}
+
+	values := make([]models.FacetValue, 0, len(counts))
+	for _, value := range counts {
+		values = append(values, *value)
+	}
+
+	SortFacetValues(values, models.FacetSortCount, false)
+
+	return values
+}
+
+// SortFacetValues sorts by count or by name. Ties are broken by name so the
+// order is stable between requests.
+func SortFacetValues(values []models.FacetValue, by string, ascending bool) {
+	sort.Slice(values, func(i, j int) bool {
+		a, b := values[i], values[j]
+		nameA, nameB := strings.ToLower(a.Name), strings.ToLower(b.Name)
+
+		if by == models.FacetSortCount && a.Count != b.Count {
+			if ascending {
+				return a.Count < b.Count
+			}
+			return a.Count > b.Count
+		}
+		if by == models.FacetSortName && nameA != nameB {
+			if ascending {
+				return nameA < nameB
+			}
+			return nameA > nameB
+		}
+		return nameA < nameB
+	})
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"pelagica-backend/jellyfin"
+	"pelagica-backend/jellyfin/jellyfintest"
+	"pelagica-backend/models"
+)
+
+func studioCounts(studios []models.StudioSummary) map[string]int {
+	counts := map[string]int{}
+	for _, studio := range studios {
+		counts[studio.Name] = studio.Count
+	}
+	return counts
+}
+
+func facetCounts(values []models.FacetValue) map[string]int {
+	counts := map[string]int{}
+	for _, value := range values {
+		counts[value.Name] = value.Count
+	}
+	return counts
+}
+
+func TestStudioIndexIncrementalBuild(t *testing.T) {
+	jf := jellyfintest.NewServer(t)
+	dir := t.TempDir()
+
+	index, err := NewStudioIndex(dir, 24*time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewStudioIndex: %v", err)
+	}
+
+	key := StudioIndexKey{JellyfinURL: jf.URL, UserID: jellyfintest.UserUserID}
+	studios, err := index.Studios(key, jellyfintest.UserToken)
+	if err != nil {
+		t.Fatalf("Studios: %v", err)
+	}
+	if got := studioCounts(studios); got["A24"] != 3 || got["Studio Ghibli"] != 2 || got["HBO"] != 2 {
+		t.Fatalf("initial studios = %v", got)
+	}
+
+	// Only the item saved after the first build should be fetched again.
+	for _, id := range []string{"movie-1", "movie-2", "movie-3", "movie-4", "series-1", "series-2"} {
+		jf.SetSavedAt(id, time.Now().Add(-time.Hour))
+	}
+	jf.UpdateItem(jellyfin.Item{
+		ID:       "movie-3",
+		Type:     "Movie",
+		ParentID: jellyfintest.MoviesLibraryID,
+		Studios:  []jellyfin.NameIDPair{{ID: "studio-ghibli", Name: "Studio Ghibli"}},
+	})
+
+	if err := index.Build(key); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	studios, _ = index.Studios(key, jellyfintest.UserToken)
+	if got := studioCounts(studios); got["A24"] != 2 || got["Studio Ghibli"] != 3 {
+		t.Errorf("studios after incremental build = %v", got)
+	}
+
+	status := index.Status()
+	if len(status) != 1 || status[0].LastBuildType != models.StudioIndexBuildIncremental || status[0].Items != 6 || status[0].Libraries != 2 || status[0].Studios != 3 {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestStudioIndexSurvivesRestart(t *testing.T) {
+	jf := jellyfintest.NewServer(t)
+	dir := t.TempDir()
+
+	index, err := NewStudioIndex(dir, 24*time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewStudioIndex: %v", err)
+	}
+
+	key := StudioIndexKey{ServerID: "home", JellyfinURL: jf.URL, UserID: jellyfintest.UserUserID}
+	if _, err := index.Studios(key, jellyfintest.UserToken); err != nil {
+		t.Fatalf("Studios: %v", err)
+	}
+
+	itemsPath := "/Users/" + jellyfintest.UserUserID + "/Items"
+	requests := jf.Requests(itemsPath)
+
+	reloaded, err := NewStudioIndex(dir, 24*time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewStudioIndex after restart: %v", err)
+	}
+
+	status := reloaded.Status()
+	if len(status) != 1 || status[0].ServerID != "home" || status[0].Scheduled {
+		t.Fatalf("unexpected status after restart %+v", status)
+	}
+
+	studios, err := reloaded.Studios(key, jellyfintest.UserToken)
+	if err != nil || len(studios) != 3 {
+		t.Fatalf("Studios after restart = %v, %v", studios, err)
+	}
+	if got := jf.Requests(itemsPath); got != requests {
+		t.Errorf("index was rebuilt after restart: %d item requests, want %d", got, requests)
+	}
+}
+
+func TestStudioIndexDropsStaleIndexes(t *testing.T) {
+	jf := jellyfintest.NewServer(t)
+	dir := t.TempDir()
+
+	index, err := NewStudioIndex(dir, 24*time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStudioIndex: %v", err)
+	}
+	key := StudioIndexKey{JellyfinURL: jf.URL, UserID: jellyfintest.UserUserID}
+	if _, err := index.Studios(key, jellyfintest.UserToken); err != nil {
+		t.Fatalf("Studios: %v", err)
+	}
+
+	// Refreshes keep failing until the index is older than maxAge.
+	jf.Fail("/Users/"+jellyfintest.UserUserID+"/Views", jellyfintest.Failure{Status: http.StatusInternalServerError})
+	index.mu.Lock()
+	index.entries[key.fileName()].data.LastBuildAt = time.Now().Add(-2 * time.Hour)
+	index.mu.Unlock()
+
+	index.RefreshAll()
+
+	if status := index.Status(); len(status) != 1 || status[0].LastBuildAt != nil || status[0].LastError == "" {
+		t.Errorf("unexpected status after failed refreshes %+v", status)
+	}
+	if _, err := index.Studios(key, jellyfintest.UserToken); err == nil {
+		t.Error("stale index was served while Jellyfin is failing")
+	}
+
+	jf.ClearFailures()
+	if studios, err := index.Studios(key, jellyfintest.UserToken); err != nil || len(studios) != 3 {
+		t.Errorf("Studios after recovery = %v, %v", studios, err)
+	}
+}
+
+func TestStudioIndexFacets(t *testing.T) {
+	jf := jellyfintest.NewServer(t)
+
+	index, err := NewStudioIndex(t.TempDir(), 24*time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewStudioIndex: %v", err)
+	}
+	key := StudioIndexKey{JellyfinURL: jf.URL, UserID: jellyfintest.UserUserID}
+
+	tests := []struct {
+		name   string
+		kind   models.FacetKind
+		filter models.FacetFilter
+		want   map[string]int
+	}{
+		{"genres", models.FacetGenres, models.FacetFilter{},
+			map[string]int{"Drama": 4, "Animation": 2, "Fantasy": 1, "Family": 1, "Comedy": 1, "History": 1}},
+		{"genres in movies library", models.FacetGenres, models.FacetFilter{LibraryIDs: []string{jellyfintest.MoviesLibraryID}},
+			map[string]int{"Drama": 2, "Animation": 2, "Fantasy": 1, "Family": 1, "Comedy": 1}},
+		{"genres in unknown library", models.FacetGenres, models.FacetFilter{LibraryIDs: []string{"unknown"}},
+			map[string]int{}},
+		{"genres in movies and unknown library", models.FacetGenres, models.FacetFilter{LibraryIDs: []string{"unknown", jellyfintest.MoviesLibraryID}},
+			map[string]int{"Drama": 2, "Animation": 2, "Fantasy": 1, "Family": 1, "Comedy": 1}},
+		{"networks", models.FacetNetworks, models.FacetFilter{},
+			map[string]int{"A24": 1, "HBO": 2}},
+		{"studios of series", models.FacetStudios, models.FacetFilter{ItemTypes: []string{"Series"}},
+			map[string]int{"A24": 1, "HBO": 2}},
+		{"decades", models.FacetDecades, models.FacetFilter{},
+			map[string]int{"1980s": 1, "2000s": 1, "2010s": 4}},
+		{"ratings", models.FacetRatings, models.FacetFilter{},
+			map[string]int{"G": 1, "PG": 1, "R": 2, "TV-MA": 2}},
+		{"directors", models.FacetPeople, models.FacetFilter{Role: "director"},
+			map[string]int{"Hayao Miyazaki": 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := index.Facet(key, jellyfintest.UserToken, tt.kind, tt.filter)
+			if err != nil {
+				t.Fatalf("Facet: %v", err)
+			}
+
+			got := facetCounts(values)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for name, count := range tt.want {
+				if got[name] != count {
+					t.Errorf("%s = %d, want %d (all: %v)", name, got[name], count, got)
+				}
+			}
+		})
+	}
+}
+
+func TestStudioIndexBoundsFacetCache(t *testing.T) {
+	jf := jellyfintest.NewServer(t)
+
+	index, err := NewStudioIndex(t.TempDir(), 24*time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewStudioIndex: %v", err)
+	}
+	key := StudioIndexKey{JellyfinURL: jf.URL, UserID: jellyfintest.UserUserID}
+
+	for i := range 2 * maxCachedFacets {
+		filter := models.FacetFilter{LibraryIDs: []string{"library-" + strconv.Itoa(i)}}
+		if _, err := index.Facet(key, jellyfintest.UserToken, models.FacetGenres, filter); err != nil {
+			t.Fatalf("Facet: %v", err)
+		}
+		filter = models.FacetFilter{Role: "role-" + strconv.Itoa(i)}
+		if _, err := index.Facet(key, jellyfintest.UserToken, models.FacetPeople, filter); err != nil {
+			t.Fatalf("Facet: %v", err)
+		}
+	}
+
+	index.mu.Lock()
+	cached := len(index.entries[key.fileName()].facets)
+	index.mu.Unlock()
+	if cached > maxCachedFacets {
+		t.Errorf("%d facets cached, want at most %d", cached, maxCachedFacets)
+	}
+}
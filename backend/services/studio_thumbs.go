@@ -0,0 +1,389 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pelagica-backend/models"
+	"pelagica-backend/persist"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// UpstreamThumbsBaseURL is the community catalog used when no mirror
+	// has a thumb for a studio.
+	UpstreamThumbsBaseURL = "https://raw.githubusercontent.com/Entree3k/Jellyfin/main/studios/"
+
+	thumbsListFile            = "thumbs.txt"
+	thumbsListRequestTimeout  = 10 * time.Second
+	thumbsListFailureBackoff  = time.Minute
+	localThumbsIndexFile      = "index.json"
+	remoteThumbContentType    = "image/webp"
+	remoteThumbFileNameSuffix = "/thumb.webp"
+)
+
+var ErrStudioThumbNotFound = errors.New("studio thumbnail not found")
+
+// NormalizeStudioName folds case and whitespace so studio names from
+// Jellyfin, thumb catalogs and uploads match each other.
+func NormalizeStudioName(name string) string {
+	parts := strings.Fields(name)
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.ToLower(strings.Join(parts, " "))
+}
+
+func studioThumbFileName(normalizedName string) string {
+	sum := sha256.Sum256([]byte(normalizedName))
+	return hex.EncodeToString(sum[:16])
+}
+
+// LocalThumbStore keeps studio thumbs uploaded by admins. Files are named by
+// a hash of the normalized studio name; index.json maps them back to the
+// name they were uploaded under.
+type LocalThumbStore struct {
+	dir    string
+	thumbs map[string]models.StudioThumb
+	mu     sync.RWMutex
+}
+
+func NewLocalThumbStore(dir string) (*LocalThumbStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	store := &LocalThumbStore{dir: dir, thumbs: map[string]models.StudioThumb{}}
+
+	data, err := os.ReadFile(filepath.Join(dir, localThumbsIndexFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		var thumbs []models.StudioThumb
+		if err := json.Unmarshal(data, &thumbs); err != nil {
+			return nil, err
+		}
+		for _, thumb := range thumbs {
+			store.thumbs[NormalizeStudioName(thumb.Name)] = thumb
+		}
+	}
+
+	return store, nil
+}
+
+func (s *LocalThumbStore) path(normalizedName string) string {
+	return filepath.Join(s.dir, studioThumbFileName(normalizedName))
+}
+
+// saveIndex must be called with s.mu held.
+func (s *LocalThumbStore) saveIndex() error {
+	thumbs := make([]models.StudioThumb, 0, len(s.thumbs))
+	for _, thumb := range s.thumbs {
+		thumbs = append(thumbs, thumb)
+	}
+	sort.Slice(thumbs, func(i, j int) bool {
+		return NormalizeStudioName(thumbs[i].Name) < NormalizeStudioName(thumbs[j].Name)
+	})
+
+	data, err := json.MarshalIndent(thumbs, "", "    ")
+	if err != nil {
+		return err
+	}
+	return persist.WriteFile(filepath.Join(s.dir, localThumbsIndexFile), data, 0644)
+}
+
+// List returns the uploaded thumbs sorted by studio name.
+func (s *LocalThumbStore) List() []models.StudioThumb {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	thumbs := make([]models.StudioThumb, 0, len(s.thumbs))
+	for _, thumb := range s.thumbs {
+		thumbs = append(thumbs, thumb)
+	}
+	sort.Slice(thumbs, func(i, j int) bool {
+		return NormalizeStudioName(thumbs[i].Name) < NormalizeStudioName(thumbs[j].Name)
+	})
+	return thumbs
+}
+
+// Get returns the upload for a studio and the path of its image.
+func (s *LocalThumbStore) Get(studioName string) (models.StudioThumb, string, bool) {
+	normalized := NormalizeStudioName(studioName)
+
+	s.mu.RLock()
+	thumb, ok := s.thumbs[normalized]
+	s.mu.RUnlock()
+
+	return thumb, s.path(normalized), ok
+}
+
+// Save stores data as the thumb of studioName, replacing an earlier upload.
+func (s *LocalThumbStore) Save(studioName string, data []byte, contentType string) (models.StudioThumb, error) {
+	normalized := NormalizeStudioName(studioName)
+	if normalized == "" {
+		return models.StudioThumb{}, errors.New("studio name is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := persist.WriteFile(s.path(normalized), data, 0644); err != nil {
+		return models.StudioThumb{}, err
+	}
+	RemoveImageVariants(s.path(normalized))
+
+	thumb := models.StudioThumb{
+		Name:        strings.Join(strings.Fields(studioName), " "),
+		Source:      models.StudioThumbSourceLocal,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		UploadedAt:  time.Now().UTC(),
+	}
+	s.thumbs[normalized] = thumb
+
+	if err := s.saveIndex(); err != nil {
+		return models.StudioThumb{}, err
+	}
+	return thumb, nil
+}
+
+// Delete removes the upload for studioName, returning ErrStudioThumbNotFound
+// if there is none.
+func (s *LocalThumbStore) Delete(studioName string) error {
+	normalized := NormalizeStudioName(studioName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.thumbs[normalized]; !ok {
+		return ErrStudioThumbNotFound
+	}
+
+	if err := os.Remove(s.path(normalized)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	RemoveImageVariants(s.path(normalized))
+	delete(s.thumbs, normalized)
+
+	return s.saveIndex()
+}
+
+// RemoteThumbSource is a catalog laid out like the upstream repository: a
+// thumbs.txt listing studio names and <name>/thumb.webp for each of them.
+type RemoteThumbSource struct {
+	baseURL   string
+	listTTL   time.Duration
+	client    *http.Client
+	refresh   singleflight.Group
+	mu        sync.RWMutex
+	names     map[string]struct{}
+	expiresAt time.Time
+
+	// lastErr is the error of the last failed refresh, which is not retried
+	// before retryAt.
+	lastErr error
+	retryAt time.Time
+}
+
+func NewRemoteThumbSource(baseURL string, listTTL time.Duration) (*RemoteThumbSource, error) {
+	parsed, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, errors.New("invalid thumb source URL: " + baseURL)
+	}
+	if !strings.HasSuffix(parsed.Path, "/") {
+		parsed.Path += "/"
+	}
+
+	return &RemoteThumbSource{
+		baseURL: parsed.String(),
+		listTTL: listTTL,
+		client:  &http.Client{Timeout: thumbsListRequestTimeout},
+	}, nil
+}
+
+func (s *RemoteThumbSource) BaseURL() string {
+	return s.baseURL
+}
+
+// ThumbURL returns where the source serves the thumb of studioName.
+func (s *RemoteThumbSource) ThumbURL(studioName string) string {
+	return s.baseURL + url.PathEscape(studioName) + remoteThumbFileNameSuffix
+}
+
+// Names returns the normalized studio names listed in thumbs.txt, refetched
+// once the list is older than the TTL. Concurrent refreshes share one
+// request. When a refresh fails, the last good list keeps being served and
+// the source is not asked again for thumbsListFailureBackoff.
+func (s *RemoteThumbSource) Names() (map[string]struct{}, error) {
+	now := time.Now()
+
+	s.mu.RLock()
+	names, expiresAt := s.names, s.expiresAt
+	lastErr, retryAt := s.lastErr, s.retryAt
+	s.mu.RUnlock()
+
+	if names != nil && now.Before(expiresAt) {
+		return names, nil
+	}
+	if lastErr != nil && now.Before(retryAt) {
+		if names != nil {
+			return names, nil
+		}
+		return nil, lastErr
+	}
+
+	result, err, _ := s.refresh.Do(thumbsListFile, func() (interface{}, error) {
+		names, err := s.fetchNames()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if err != nil {
+			s.lastErr = err
+			s.retryAt = time.Now().Add(thumbsListFailureBackoff)
+			log.Printf("studios: thumbs list from %s unavailable, retrying in %s: %v", s.baseURL, thumbsListFailureBackoff, err)
+			return s.names, err
+		}
+
+		s.names = names
+		s.expiresAt = time.Now().Add(s.listTTL)
+		s.lastErr = nil
+		log.Printf("studios: thumbs list from %s refreshed (%d entries)", s.baseURL, len(names))
+		return names, nil
+	})
+
+	names = result.(map[string]struct{})
+	if err != nil && names == nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (s *RemoteThumbSource) fetchNames() (map[string]struct{}, error) {
+	resp, err := s.client.Get(s.baseURL + thumbsListFile)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("failed to fetch thumbs list: status=" + strconv.Itoa(resp.StatusCode))
+	}
+
+	names := map[string]struct{}{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names[NormalizeStudioName(line)] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// StudioThumbMatch says where the thumb of a studio comes from. Exactly one
+// of LocalPath and RemoteURL is set.
+type StudioThumbMatch struct {
+	Source      string
+	LocalPath   string
+	RemoteURL   string
+	ContentType string
+}
+
+// ThumbSources is the chain of places studio thumbs are looked up in: admin
+// uploads first, then each remote source in the configured order.
+type ThumbSources struct {
+	Local   *LocalThumbStore
+	Remotes []*RemoteThumbSource
+}
+
+// Find returns the first source that has a thumb for studioName, or
+// ErrStudioThumbNotFound. Remote sources whose list cannot be fetched are
+// skipped; an error is only returned if no source could answer at all.
+func (t *ThumbSources) Find(studioName string) (StudioThumbMatch, error) {
+	if thumb, path, ok := t.Local.Get(studioName); ok {
+		return StudioThumbMatch{
+			Source:      models.StudioThumbSourceLocal,
+			LocalPath:   path,
+			ContentType: thumb.ContentType,
+		}, nil
+	}
+
+	normalized := NormalizeStudioName(studioName)
+
+	var lastErr error
+	answered := false
+	for _, remote := range t.Remotes {
+		names, err := remote.Names()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		answered = true
+
+		if _, ok := names[normalized]; ok {
+			return StudioThumbMatch{
+				Source:      remote.BaseURL(),
+				RemoteURL:   remote.ThumbURL(studioName),
+				ContentType: remoteThumbContentType,
+			}, nil
+		}
+	}
+
+	if !answered && lastErr != nil {
+		return StudioThumbMatch{}, lastErr
+	}
+	return StudioThumbMatch{}, ErrStudioThumbNotFound
+}
+
+// Available returns the set of normalized studio names any source has a
+// thumb for. Like Find, unreachable remote sources are skipped.
+func (t *ThumbSources) Available() (map[string]struct{}, error) {
+	available := map[string]struct{}{}
+	for _, thumb := range t.Local.List() {
+		available[NormalizeStudioName(thumb.Name)] = struct{}{}
+	}
+
+	var lastErr error
+	answered := len(t.Remotes) == 0 || len(available) > 0
+	for _, remote := range t.Remotes {
+		names, err := remote.Names()
+		if err != nil {
+			log.Printf("studios: thumbs list from %s unavailable: %v", remote.BaseURL(), err)
+			lastErr = err
+			continue
+		}
+		answered = true
+
+		for name := range names {
+			available[name] = struct{}{}
+		}
+	}
+
+	if !answered {
+		return nil, lastErr
+	}
+	return available, nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemoteThumbSourceNames(t *testing.T) {
+	var requests atomic.Int32
+	var failing atomic.Bool
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("A24\n# comment\nStudio Ghibli\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	source, err := NewRemoteThumbSource(server.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRemoteThumbSource: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if names, err := source.Names(); err != nil || len(names) != 2 {
+				t.Errorf("Names() = %v, %v", names, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("concurrent refreshes made %d requests, want 1", got)
+	}
+
+	// Expire the list and let the next refresh fail: the last good list is
+	// served and the failure is not retried until the backoff has passed.
+	failing.Store(true)
+	source.mu.Lock()
+	source.expiresAt = time.Now()
+	source.mu.Unlock()
+
+	for range 3 {
+		if names, err := source.Names(); err != nil || len(names) != 2 {
+			t.Errorf("Names() after failure = %v, %v", names, err)
+		}
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("made %d requests in total, want 2", got)
+	}
+}
+
+func TestRemoteThumbSourceFailureWithoutList(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	source, err := NewRemoteThumbSource(server.URL, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRemoteThumbSource: %v", err)
+	}
+
+	for range 3 {
+		if _, err := source.Names(); err == nil {
+			t.Error("Names() succeeded without a list")
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("made %d requests during the backoff, want 1", got)
+	}
+}
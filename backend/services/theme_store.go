@@ -3,11 +3,15 @@ package services
 import (
 	"encoding/json"
 	"errors"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	"pelagica-backend/models"
+	"pelagica-backend/persist"
 
 	"github.com/google/uuid"
 )
@@ -35,12 +39,16 @@ func NewThemeStore(dir string) (*ThemeStore, error) {
 	return store, nil
 }
 
+// loadAll rebuilds the theme map from disk. Themes that fail to load keep
+// their previously loaded version so a broken edit does not drop them.
 func (s *ThemeStore) loadAll() error {
 	files, err := os.ReadDir(s.dir)
 	if err != nil {
 		return err
 	}
 
+	themes := make(map[string]models.Theme, len(files))
+
 	for _, file := range files {
 		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
 			continue
@@ -51,20 +59,44 @@ func (s *ThemeStore) loadAll() error {
 
 		data, err := os.ReadFile(path)
 		if err != nil {
+			log.Printf("Error reading theme %s: %v", id, err)
+			if previous, ok := s.themes[id]; ok {
+				themes[id] = previous
+			}
 			continue
 		}
 
 		var theme models.Theme
 		if err := json.Unmarshal(data, &theme); err != nil {
+			log.Printf("Error parsing theme %s: %v", id, err)
+			if previous, ok := s.themes[id]; ok {
+				themes[id] = previous
+			}
 			continue
 		}
 
-		s.themes[id] = theme
+		themes[id] = theme
 	}
 
+	s.themes = themes
+
 	return nil
 }
 
+// Reload rescans the themes directory, picking up files that were added,
+// changed or removed outside of the store.
+func (s *ThemeStore) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.loadAll()
+}
+
+// Dir returns the directory the store reads themes from.
+func (s *ThemeStore) Dir() string {
+	return s.dir
+}
+
 func (s *ThemeStore) GetAll() []models.ThemeSummary {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -79,10 +111,35 @@ func (s *ThemeStore) GetAll() []models.ThemeSummary {
 			Author:  theme.Author,
 		})
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
 
 	return result
 }
 
+// ModTime returns when the theme with the given ID last changed on disk, or
+// with an empty ID when any theme was last added, changed or removed.
+func (s *ThemeStore) ModTime(id string) time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	paths := []string{s.dir}
+	if id != "" {
+		paths = append(paths, filepath.Join(s.dir, id+".json"))
+	} else {
+		for themeID := range s.themes {
+			paths = append(paths, filepath.Join(s.dir, themeID+".json"))
+		}
+	}
+
+	var latest time.Time
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
 func (s *ThemeStore) Get(id string) (models.Theme, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -110,7 +167,7 @@ func (s *ThemeStore) Write(id string, theme models.Theme) (string, error) {
 
 	path := filepath.Join(s.dir, id+".json")
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := persist.WriteFileWithBackup(path, data, 0644); err != nil {
 		return "", err
 	}
 
@@ -0,0 +1,527 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pelagica-backend/models"
+	"pelagica-backend/persist"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	thumbCacheTempSubdir   = ".tmp"
+	thumbCacheDataExt      = ".webp"
+	thumbCacheMetaExt      = ".json"
+	thumbDownloadTimeout   = 10 * time.Second
+	thumbAccessTouchPeriod = time.Minute
+
+	// maxNegativeThumbs bounds how many missing thumbs are remembered, as
+	// studio names come from clients.
+	maxNegativeThumbs = 4096
+)
+
+// thumbCacheMeta is stored next to each cached thumb so it can be
+// revalidated and attributed to its studio after a restart.
+type thumbCacheMeta struct {
+	URL          string    `json:"url"`
+	Studio       string    `json:"studio"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// thumbCacheEntry is a cached thumb. Its size includes the resized
+// variants rendered from it, which are kept by path.
+type thumbCacheEntry struct {
+	meta       thumbCacheMeta
+	size       int64
+	variants   map[string]int64
+	lastAccess time.Time
+}
+
+type negativeThumbEntry struct {
+	studio    string
+	expiresAt time.Time
+}
+
+// ThumbCache keeps thumbs downloaded from remote sources on disk. It is
+// bounded by maxBytes, evicting the least recently used thumbs first,
+// revalidates thumbs older than revalidateAfter in the background and
+// remembers missing thumbs for negativeTTL. Resized variants count towards
+// maxBytes and are removed along with their thumb.
+type ThumbCache struct {
+	dir             string
+	maxBytes        int64
+	revalidateAfter time.Duration
+	negativeTTL     time.Duration
+	client          *http.Client
+	fetches         singleflight.Group
+
+	mu       sync.Mutex
+	entries  map[string]*thumbCacheEntry
+	negative map[string]negativeThumbEntry
+	size     int64
+	stats    models.ThumbCacheStats
+}
+
+func NewThumbCache(dir string, maxBytes int64, revalidateAfter, negativeTTL time.Duration) (*ThumbCache, error) {
+	cache := &ThumbCache{
+		dir:             dir,
+		maxBytes:        maxBytes,
+		revalidateAfter: revalidateAfter,
+		negativeTTL:     negativeTTL,
+		client:          &http.Client{Timeout: thumbDownloadTimeout},
+		entries:         map[string]*thumbCacheEntry{},
+		negative:        map[string]negativeThumbEntry{},
+	}
+
+	if err := cache.sweepTemp(); err != nil {
+		return nil, err
+	}
+	if err := cache.load(); err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	cache.evictLocked("")
+	cache.mu.Unlock()
+
+	return cache, nil
+}
+
+// sweepTemp removes downloads left behind by a crash. Nothing can be in
+// flight yet when the cache is created.
+func (c *ThumbCache) sweepTemp() error {
+	tmpDir := filepath.Join(c.dir, thumbCacheTempSubdir)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return err
+	}
+
+	files, err := os.ReadDir(tmpDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := os.RemoveAll(filepath.Join(tmpDir, file.Name())); err != nil {
+			log.Printf("thumb cache: failed to remove temp file %s: %v", file.Name(), err)
+		}
+	}
+	if len(files) > 0 {
+		log.Printf("thumb cache: removed %d stale temp files", len(files))
+	}
+
+	return nil
+}
+
+// load indexes the cached thumbs. Thumbs without metadata, which includes
+// everything cached before metadata was kept, metadata without a thumb and
+// metadata or variant writes interrupted by a crash are removed.
+func (c *ThumbCache) load() error {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || filepath.Ext(name) != thumbCacheMetaExt {
+			continue
+		}
+		key := strings.TrimSuffix(name, thumbCacheMetaExt)
+
+		data, err := os.ReadFile(filepath.Join(c.dir, name))
+		var meta thumbCacheMeta
+		if err == nil {
+			err = json.Unmarshal(data, &meta)
+		}
+		info, statErr := os.Stat(c.dataPath(key))
+		if err != nil || statErr != nil {
+			c.removeFiles(key)
+			continue
+		}
+
+		c.entries[key] = &thumbCacheEntry{meta: meta, size: info.Size(), variants: map[string]int64{}, lastAccess: info.ModTime()}
+		c.size += info.Size()
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() {
+			continue
+		}
+		if persist.IsTempFile(name) {
+			os.Remove(filepath.Join(c.dir, name))
+			continue
+		}
+
+		// Resized variants are kept as long as their thumb is.
+		key := name
+		if IsImageVariant(name) {
+			key = name[:strings.Index(name, imageVariantInfix)]
+		} else if filepath.Ext(name) != thumbCacheDataExt {
+			continue
+		}
+		entry, ok := c.entries[strings.TrimSuffix(key, thumbCacheDataExt)]
+		if !ok {
+			os.Remove(filepath.Join(c.dir, name))
+			continue
+		}
+		if key != name {
+			info, err := file.Info()
+			if err != nil {
+				continue
+			}
+			entry.variants[filepath.Join(c.dir, name)] = info.Size()
+			entry.size += info.Size()
+			c.size += info.Size()
+		}
+	}
+
+	return nil
+}
+
+func (c *ThumbCache) dataPath(key string) string {
+	return filepath.Join(c.dir, key+thumbCacheDataExt)
+}
+
+func (c *ThumbCache) metaPath(key string) string {
+	return filepath.Join(c.dir, key+thumbCacheMetaExt)
+}
+
+func (c *ThumbCache) removeFiles(key string) {
+	os.Remove(c.dataPath(key))
+	os.Remove(c.metaPath(key))
+	RemoveImageVariants(c.dataPath(key))
+}
+
+// Get returns the path of the cached thumb downloaded from thumbURL,
+// downloading it first on a miss. A thumb the source does not have returns
+// ErrStudioThumbNotFound, which is remembered for the negative TTL.
+func (c *ThumbCache) Get(studioName, thumbURL string) (string, error) {
+	key := studioThumbFileName(thumbURL)
+	now := time.Now()
+
+	c.mu.Lock()
+	if negative, ok := c.negative[thumbURL]; ok {
+		if now.Before(negative.expiresAt) {
+			c.stats.NegativeHits++
+			c.mu.Unlock()
+			return "", ErrStudioThumbNotFound
+		}
+		delete(c.negative, thumbURL)
+	}
+
+	if entry, ok := c.entries[key]; ok {
+		c.stats.Hits++
+		if now.Sub(entry.lastAccess) >= thumbAccessTouchPeriod {
+			entry.lastAccess = now
+			os.Chtimes(c.dataPath(key), now, now)
+		}
+		stale := c.revalidateAfter > 0 && now.Sub(entry.meta.FetchedAt) >= c.revalidateAfter
+		c.mu.Unlock()
+
+		if stale {
+			go func() {
+				if _, err := c.fetch(key, studioName, thumbURL); err != nil && !errors.Is(err, ErrStudioThumbNotFound) {
+					log.Printf("thumb cache: revalidating %s failed: %v", thumbURL, err)
+				}
+			}()
+		}
+		return c.dataPath(key), nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	return c.fetch(key, studioName, thumbURL)
+}
+
+// fetch downloads or revalidates thumbURL. Concurrent fetches of the same
+// thumb collapse into one request.
+func (c *ThumbCache) fetch(key, studioName, thumbURL string) (string, error) {
+	_, err, _ := c.fetches.Do(key, func() (interface{}, error) {
+		return nil, c.download(key, studioName, thumbURL)
+	})
+	if err != nil {
+		return "", err
+	}
+	return c.dataPath(key), nil
+}
+
+func (c *ThumbCache) download(key, studioName, thumbURL string) error {
+	req, err := http.NewRequest(http.MethodGet, thumbURL, nil)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	entry, cached := c.entries[key]
+	if cached {
+		if entry.meta.ETag != "" {
+			req.Header.Set("If-None-Match", entry.meta.ETag)
+		}
+		if entry.meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.meta.LastModified)
+		}
+	}
+	c.mu.Unlock()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	meta := thumbCacheMeta{
+		URL:          thumbURL,
+		Studio:       NormalizeStudioName(studioName),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now().UTC(),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		c.mu.Lock()
+		if meta.ETag == "" {
+			meta.ETag = entry.meta.ETag
+		}
+		if meta.LastModified == "" {
+			meta.LastModified = entry.meta.LastModified
+		}
+		entry.meta = meta
+		c.stats.Revalidations++
+		c.mu.Unlock()
+		return c.writeMeta(key, meta)
+
+	case resp.StatusCode == http.StatusNotFound:
+		c.mu.Lock()
+		c.removeLocked(key)
+		if c.negativeTTL > 0 {
+			c.rememberMissingLocked(thumbURL, meta.Studio)
+		}
+		c.mu.Unlock()
+		return ErrStudioThumbNotFound
+
+	case resp.StatusCode != http.StatusOK:
+		return errors.New("upstream returned status " + strconv.Itoa(resp.StatusCode))
+	}
+
+	size, err := c.writeData(key, resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := c.writeMeta(key, meta); err != nil {
+		os.Remove(c.dataPath(key))
+		return err
+	}
+
+	c.mu.Lock()
+	if previous, ok := c.entries[key]; ok {
+		// The variants of the old thumb would be rendered again anyway.
+		RemoveImageVariants(c.dataPath(key))
+		c.size -= previous.size
+	}
+	c.entries[key] = &thumbCacheEntry{meta: meta, size: size, variants: map[string]int64{}, lastAccess: time.Now()}
+	c.size += size
+	if cached {
+		c.stats.Revalidations++
+	}
+	c.evictLocked(key)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// RenderVariant renders variant of the cached thumb at path like
+// RenderImageVariant, counting the rendering towards maxBytes.
+func (c *ThumbCache) RenderVariant(path string, variant ImageVariant) (string, string, error) {
+	variantPath, contentType, err := RenderImageVariant(path, variant)
+	if err != nil {
+		return "", "", err
+	}
+	info, err := os.Stat(variantPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := strings.TrimSuffix(filepath.Base(path), thumbCacheDataExt)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A thumb removed while its variant was rendered leaves the variant
+	// behind until the next startup.
+	entry, ok := c.entries[key]
+	if !ok {
+		return variantPath, contentType, nil
+	}
+	growth := info.Size() - entry.variants[variantPath]
+	entry.variants[variantPath] = info.Size()
+	entry.size += growth
+	c.size += growth
+	c.evictLocked(key)
+
+	return variantPath, contentType, nil
+}
+
+// writeData writes the thumb atomically through a temp file in .tmp so
+// partial writes are never visible.
+func (c *ThumbCache) writeData(key string, body io.Reader) (int64, error) {
+	tmpFile, err := os.CreateTemp(filepath.Join(c.dir, thumbCacheTempSubdir), "thumb-*"+thumbCacheDataExt)
+	if err != nil {
+		return 0, err
+	}
+	tmpPath := tmpFile.Name()
+
+	size, err := io.Copy(tmpFile, body)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, c.dataPath(key))
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+
+	return size, nil
+}
+
+func (c *ThumbCache) writeMeta(key string, meta thumbCacheMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return persist.WriteFile(c.metaPath(key), data, 0644)
+}
+
+// rememberMissingLocked records that thumbURL does not exist. Expired
+// entries are pruned once maxNegativeThumbs is reached, and if all of them
+// are current an arbitrary one is forgotten, which only costs a request.
+// It must be called with c.mu held.
+func (c *ThumbCache) rememberMissingLocked(thumbURL, studio string) {
+	now := time.Now()
+
+	if _, ok := c.negative[thumbURL]; !ok && len(c.negative) >= maxNegativeThumbs {
+		for url, negative := range c.negative {
+			if !now.Before(negative.expiresAt) {
+				delete(c.negative, url)
+			}
+		}
+		for url := range c.negative {
+			if len(c.negative) < maxNegativeThumbs {
+				break
+			}
+			delete(c.negative, url)
+		}
+	}
+
+	c.negative[thumbURL] = negativeThumbEntry{studio: studio, expiresAt: now.Add(c.negativeTTL)}
+}
+
+// removeLocked must be called with c.mu held.
+func (c *ThumbCache) removeLocked(key string) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.removeFiles(key)
+	c.size -= entry.size
+	delete(c.entries, key)
+}
+
+// evictLocked removes the least recently used thumbs and their variants
+// until the cache fits in maxBytes, keeping keep, the thumb that was just
+// added or rendered. It must be
+// called with c.mu held.
+func (c *ThumbCache) evictLocked(keep string) {
+	if c.maxBytes <= 0 || c.size <= c.maxBytes {
+		return
+	}
+
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		if key != keep {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].lastAccess.Before(c.entries[keys[j]].lastAccess)
+	})
+
+	for _, key := range keys {
+		if c.size <= c.maxBytes {
+			break
+		}
+		c.removeLocked(key)
+		c.stats.Evictions++
+	}
+}
+
+// Stats reports the cache size and counters since startup.
+func (c *ThumbCache) Stats() models.ThumbCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.SizeBytes = c.size
+	stats.MaxSizeBytes = c.maxBytes
+	for _, negative := range c.negative {
+		if now.Before(negative.expiresAt) {
+			stats.NegativeEntries++
+		}
+	}
+	return stats
+}
+
+// Purge removes every cached thumb and forgets missing ones. It returns the
+// number of cached thumbs removed.
+func (c *ThumbCache) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := len(c.entries)
+	for key := range c.entries {
+		c.removeLocked(key)
+	}
+	c.negative = map[string]negativeThumbEntry{}
+	return removed
+}
+
+// PurgeStudio removes the cached thumbs of one studio, from every source,
+// and forgets that it was missing anywhere.
+func (c *ThumbCache) PurgeStudio(studioName string) int {
+	normalized := NormalizeStudioName(studioName)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, entry := range c.entries {
+		if entry.meta.Studio == normalized {
+			c.removeLocked(key)
+			removed++
+		}
+	}
+	for thumbURL, negative := range c.negative {
+		if negative.studio == normalized {
+			delete(c.negative, thumbURL)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const thumbOriginETag = `"v1"`
+
+// thumbOrigin serves the same 10 byte thumb with a fixed ETag for every path
+// except /missing/.
+type thumbOrigin struct {
+	*httptest.Server
+	mu          sync.Mutex
+	requests    map[string]int
+	notModified int
+}
+
+func newThumbOrigin(t *testing.T) *thumbOrigin {
+	origin := &thumbOrigin{requests: map[string]int{}}
+	origin.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin.mu.Lock()
+		defer origin.mu.Unlock()
+
+		origin.requests[r.URL.Path]++
+		if strings.HasPrefix(r.URL.Path, "/missing/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Header.Get("If-None-Match") == thumbOriginETag {
+			origin.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", thumbOriginETag)
+		w.Write([]byte("0123456789"))
+	}))
+	t.Cleanup(origin.Close)
+	return origin
+}
+
+func (o *thumbOrigin) count(path string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.requests[path]
+}
+
+func TestThumbCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	origin := newThumbOrigin(t)
+	cache, err := NewThumbCache(t.TempDir(), 25, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("NewThumbCache: %v", err)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if _, err := cache.Get(name, origin.URL+"/"+name+"/thumb.webp"); err != nil {
+			t.Fatalf("Get %s: %v", name, err)
+		}
+	}
+
+	// Make a the most recently used thumb, so adding c evicts b.
+	cache.mu.Lock()
+	cache.entries[studioThumbFileName(origin.URL+"/b/thumb.webp")].lastAccess = time.Now().Add(-time.Hour)
+	cache.mu.Unlock()
+
+	if _, err := cache.Get("c", origin.URL+"/c/thumb.webp"); err != nil {
+		t.Fatalf("Get c: %v", err)
+	}
+
+	stats := cache.Stats()
+	if stats.Entries != 2 || stats.SizeBytes != 20 || stats.Evictions != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	cache.Get("a", origin.URL+"/a/thumb.webp")
+	cache.Get("b", origin.URL+"/b/thumb.webp")
+	if origin.count("/a/thumb.webp") != 1 || origin.count("/b/thumb.webp") != 2 {
+		t.Errorf("a fetched %d times, b %d times; want 1 and 2", origin.count("/a/thumb.webp"), origin.count("/b/thumb.webp"))
+	}
+}
+
+func TestThumbCacheRevalidatesWithETag(t *testing.T) {
+	origin := newThumbOrigin(t)
+	cache, err := NewThumbCache(t.TempDir(), 0, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("NewThumbCache: %v", err)
+	}
+
+	thumbURL := origin.URL + "/a/thumb.webp"
+	if _, err := cache.Get("a", thumbURL); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	cache.mu.Lock()
+	cache.entries[studioThumbFileName(thumbURL)].meta.FetchedAt = time.Now().Add(-2 * time.Hour)
+	cache.mu.Unlock()
+
+	if _, err := cache.Get("a", thumbURL); err != nil {
+		t.Fatalf("Get stale: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for cache.Stats().Revalidations == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	origin.mu.Lock()
+	notModified := origin.notModified
+	origin.mu.Unlock()
+	if stats := cache.Stats(); stats.Revalidations != 1 || notModified != 1 {
+		t.Errorf("revalidations = %d, not modified responses = %d; want 1 and 1", stats.Revalidations, notModified)
+	}
+}
+
+func TestThumbCacheRemembersMissingThumbs(t *testing.T) {
+	origin := newThumbOrigin(t)
+	cache, err := NewThumbCache(t.TempDir(), 0, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("NewThumbCache: %v", err)
+	}
+
+	thumbURL := origin.URL + "/missing/thumb.webp"
+	for range 3 {
+		if _, err := cache.Get("Missing", thumbURL); !errors.Is(err, ErrStudioThumbNotFound) {
+			t.Fatalf("Get = %v, want ErrStudioThumbNotFound", err)
+		}
+	}
+	if got := origin.count("/missing/thumb.webp"); got != 1 {
+		t.Errorf("missing thumb fetched %d times, want 1", got)
+	}
+
+	cache.PurgeStudio("missing")
+	cache.Get("Missing", thumbURL)
+	if got := origin.count("/missing/thumb.webp"); got != 2 {
+		t.Errorf("missing thumb fetched %d times after purge, want 2", got)
+	}
+}
+
+func TestThumbCacheBoundsMissingThumbs(t *testing.T) {
+	cache, err := NewThumbCache(t.TempDir(), 0, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("NewThumbCache: %v", err)
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for i := range maxNegativeThumbs + 10 {
+		cache.rememberMissingLocked("https://thumbs.example/"+strconv.Itoa(i), "studio")
+	}
+	if got := len(cache.negative); got != maxNegativeThumbs {
+		t.Errorf("%d missing thumbs remembered, want %d", got, maxNegativeThumbs)
+	}
+
+	for url, negative := range cache.negative {
+		negative.expiresAt = time.Now().Add(-time.Second)
+		cache.negative[url] = negative
+	}
+	cache.rememberMissingLocked("https://thumbs.example/new", "studio")
+	if got := len(cache.negative); got != 1 {
+		t.Errorf("%d missing thumbs remembered after expiry, want 1", got)
+	}
+}
+
+func TestThumbCacheCleansUpAtStartup(t *testing.T) {
+	origin := newThumbOrigin(t)
+	dir := t.TempDir()
+
+	cache, err := NewThumbCache(dir, 0, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("NewThumbCache: %v", err)
+	}
+	if _, err := cache.Get("a", origin.URL+"/a/thumb.webp"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	stale := filepath.Join(dir, thumbCacheTempSubdir, "thumb-123.webp")
+	orphan := filepath.Join(dir, "0123456789abcdef.webp")
+	interrupted := filepath.Join(dir, ".0123456789abcdef.json.tmp-123")
+	for _, path := range []string{stale, orphan, interrupted} {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded, err := NewThumbCache(dir, 0, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("NewThumbCache after restart: %v", err)
+	}
+
+	for _, path := range []string{stale, orphan, interrupted} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", filepath.Base(path))
+		}
+	}
+	if stats := reloaded.Stats(); stats.Entries != 1 || stats.SizeBytes != 10 {
+		t.Errorf("unexpected stats after restart %+v", stats)
+	}
+}
+
+func TestThumbCacheCountsVariants(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "thumb.png")
+	writeTestPNG(t, source, 64, 64)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, source)
+	}))
+	t.Cleanup(origin.Close)
+
+	dir := t.TempDir()
+	cache, err := NewThumbCache(dir, 0, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("NewThumbCache: %v", err)
+	}
+	pathA, err := cache.Get("a", origin.URL+"/a/thumb.png")
+	if err != nil {
+		t.Fatalf("Get a: %v", err)
+	}
+	if _, err := cache.Get("b", origin.URL+"/b/thumb.png"); err != nil {
+		t.Fatalf("Get b: %v", err)
+	}
+	thumbsSize := cache.Stats().SizeBytes
+
+	variant := ImageVariant{Width: 32, Format: ImageFormatWebP}
+	variantPath, _, err := cache.RenderVariant(pathA, variant)
+	if err != nil {
+		t.Fatalf("RenderVariant: %v", err)
+	}
+	info, err := os.Stat(variantPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cache.Stats().SizeBytes, thumbsSize+info.Size(); got != want {
+		t.Errorf("size with variant = %d, want %d", got, want)
+	}
+	if _, _, err := cache.RenderVariant(pathA, variant); err != nil {
+		t.Fatalf("RenderVariant again: %v", err)
+	}
+	if got, want := cache.Stats().SizeBytes, thumbsSize+info.Size(); got != want {
+		t.Errorf("size after rendering again = %d, want %d", got, want)
+	}
+
+	reloaded, err := NewThumbCache(dir, 0, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("NewThumbCache after restart: %v", err)
+	}
+	if got, want := reloaded.Stats().SizeBytes, thumbsSize+info.Size(); got != want {
+		t.Errorf("size after restart = %d, want %d", got, want)
+	}
+
+	// With room for the thumbs only, the variant of a evicts b.
+	reloaded.mu.Lock()
+	reloaded.maxBytes = thumbsSize
+	reloaded.mu.Unlock()
+	if _, _, err := reloaded.RenderVariant(pathA, ImageVariant{Width: 64, Format: ImageFormatJPEG}); err != nil {
+		t.Fatalf("RenderVariant: %v", err)
+	}
+	if stats := reloaded.Stats(); stats.Entries != 1 || stats.Evictions != 1 {
+		t.Errorf("unexpected stats after eviction %+v", stats)
+	}
+	if _, err := os.Stat(pathA); err != nil {
+		t.Errorf("thumb a was evicted: %v", err)
+	}
+}
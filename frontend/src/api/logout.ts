@@ -1,8 +1,16 @@
-import { clearCredentials } from '@/utils/localstorageCredentials';
+import { clearCredentials, getAccessToken } from '@/utils/localstorageCredentials';
 import { clearDeviceId } from '@/utils/deviceId';
 
 // eslint-disable-next-line @typescript-eslint/no-explicit-any
 export async function logout(api: any) {
+    const token = getAccessToken();
+    if (token) {
+        // Drop the backend's cached validation of this token
+        await fetch('/api/auth/logout', {
+            method: 'POST',
+            headers: { Authorization: token },
+        }).catch(() => undefined);
+    }
     clearCredentials();
     clearDeviceId();
     await api.logout();
@@ -0,0 +1,56 @@
+import { useConfig } from '@/hooks/api/useConfig';
+import { useEffect } from 'react';
+
+/** Icon links in index.html, keyed by the size generated for them by the backend */
+const ICON_LINKS: { selector: string; size: number; type?: string }[] = [
+    { selector: 'link[rel="icon"][sizes="96x96"]', size: 96 },
+    { selector: 'link[rel="icon"][type="image/svg+xml"]', size: 32, type: 'image/png' },
+    { selector: 'link[rel="shortcut icon"]', size: 32, type: 'image/png' },
+    { selector: 'link[rel="apple-touch-icon"]', size: 180 },
+];
+
+/** Builds the URL of an icon size from the versioned icon URL in the config */
+function getBrandingIconUrl(iconUrl: string, size: number): string {
+    const [path, query] = iconUrl.split('?');
+    return `${path}/${size}${query ? `?${query}` : ''}`;
+}
+
+/** Points the favicon and apple-touch icon at the uploaded app icon */
+const BrandingIconsLoader = () => {
+    const { config } = useConfig();
+
+    useEffect(() => {
+        const iconUrl = config?.iconUrl;
+        const originals: [HTMLLinkElement, string, string | null][] = [];
+
+        if (iconUrl) {
+            for (const { selector, size, type } of ICON_LINKS) {
+                const link = document.head.querySelector<HTMLLinkElement>(selector);
+                if (!link) continue;
+
+                originals.push([link, link.href, link.getAttribute('type')]);
+                link.href = getBrandingIconUrl(iconUrl, size);
+                if (type) link.type = type;
+            }
+        }
+
+        const titleMeta = document.head.querySelector<HTMLMetaElement>(
+            'meta[name="apple-mobile-web-app-title"]'
+        );
+        const originalTitle = titleMeta?.content;
+        if (titleMeta && config?.serverName) titleMeta.content = config.serverName;
+
+        return () => {
+            for (const [link, href, type] of originals) {
+                link.href = href;
+                if (type) link.setAttribute('type', type);
+                else link.removeAttribute('type');
+            }
+            if (titleMeta && originalTitle !== undefined) titleMeta.content = originalTitle;
+        };
+    }, [config?.iconUrl, config?.serverName]);
+
+    return null;
+};
+
+export default BrandingIconsLoader;
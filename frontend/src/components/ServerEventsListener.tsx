@@ -0,0 +1,33 @@
+import { useQueryClient } from '@tanstack/react-query';
+import { useEffect } from 'react';
+
+/** Refreshes cached config and themes when the backend reports a change */
+const ServerEventsListener = () => {
+    const queryClient = useQueryClient();
+
+    useEffect(() => {
+        const source = new EventSource('/api/events');
+
+        const invalidateConfig = () => {
+            queryClient.invalidateQueries({ queryKey: ['config'] });
+        };
+        const invalidateThemes = () => {
+            queryClient.invalidateQueries({ queryKey: ['themes'] });
+        };
+
+        source.addEventListener('config-updated', invalidateConfig);
+        source.addEventListener('server-theme-changed', invalidateConfig);
+        source.addEventListener('branding-logo-changed', invalidateConfig);
+        source.addEventListener('branding-icon-changed', invalidateConfig);
+        source.addEventListener('branding-login-background-changed', invalidateConfig);
+        source.addEventListener('theme-created', invalidateThemes);
+        source.addEventListener('theme-updated', invalidateThemes);
+        source.addEventListener('theme-deleted', invalidateThemes);
+
+        return () => source.close();
+    }, [queryClient]);
+
+    return null;
+};
+
+export default ServerEventsListener;
@@ -202,6 +202,8 @@ export interface ConfigLink {
 }
 
 export interface AppConfig {
+    /** Config format version, maintained by the backend */
+    configVersion?: number;
     /** Optional server address to automatically choose */
     serverAddress?: string;
     /** Optional URL for Streamystats integration */
@@ -228,8 +230,14 @@ export interface AppConfig {
     logoLightUrl?: string;
     /** URL for the dark mode logo */
     logoDarkUrl?: string;
+    /** Versioned URL of the uploaded app icon, set by the backend */
+    iconUrl?: string;
+    /** Versioned URL of the uploaded login page background, set by the backend */
+    loginBackgroundUrl?: string;
     /** Links to display in the UI */
     links?: ConfigLink[];
+    /** JSON pointers of fields users cannot override in their personal config */
+    lockedFields?: string[];
 }
 
 const DEFAULT_ITEM_PAGE_SETTINGS: ItemPageSettings = {
@@ -329,33 +337,71 @@ const DEFAULT_CONFIG: AppConfig = {
 };
 
 const CONFIG_QUERY_KEY = ['config'] as const;
+/** The global config without server or user overlays, as edited in the settings */
+const GLOBAL_CONFIG_QUERY_KEY = ['config', 'global'] as const;
 
-const fetchConfig = async (): Promise<AppConfig> => {
-    const response = await fetch('/api/config');
+interface ConfigQueryData {
+    config: AppConfig;
+    /** ETag of the loaded config, sent as If-Match when saving */
+    etag: string | null;
+}
+
+/** Thrown when the config was changed by someone else since it was loaded */
+export class ConfigConflictError extends Error {
+    constructor() {
+        super('Config has been modified since it was loaded');
+        this.name = 'ConfigConflictError';
+    }
+}
+
+const withDefaults = (config: AppConfig): AppConfig => ({
+    ...DEFAULT_CONFIG,
+    ...config,
+    itemPage: {
+        ...DEFAULT_ITEM_PAGE_SETTINGS,
+        ...config.itemPage,
+    },
+});
+
+/** Fetches the config as the signed in user, so their overlay is merged in */
+const fetchConfig = async (path: string): Promise<ConfigQueryData> => {
+    const server = getServerUrl();
+    const token = getAccessToken();
+    const url = server
+        ? `${path}${path.includes('?') ? '&' : '?'}jellyfin_url=${encodeURIComponent(server)}`
+        : path;
+    const response = await fetch(url, { headers: token ? { Authorization: token } : {} });
     if (!response.ok) {
         console.warn('Config file not found, using default configuration');
-        return DEFAULT_CONFIG;
+        return { config: DEFAULT_CONFIG, etag: null };
     }
     const data: AppConfig = await response.json();
     // Merge with defaults to ensure all required fields exist
+    return { config: withDefaults(data), etag: response.headers.get('ETag') };
+};
+
+export const useConfig = () => {
+    const { data, isLoading, error } = useQuery({
+        queryKey: [...CONFIG_QUERY_KEY, 'caller', getAccessToken() ?? ''],
+        queryFn: () => fetchConfig('/api/config'),
+    });
+
     return {
-        ...DEFAULT_CONFIG,
-        ...data,
-        itemPage: {
-            ...DEFAULT_ITEM_PAGE_SETTINGS,
-            ...data.itemPage,
-        },
+        config: data?.config ?? DEFAULT_CONFIG,
+        loading: isLoading,
+        error: error instanceof Error ? error.message : error ? String(error) : null,
     };
 };
 
-export const useConfig = () => {
+/** Loads the global config for editing, see useUpdateConfig */
+export const useGlobalConfig = () => {
     const { data, isLoading, error } = useQuery({
-        queryKey: CONFIG_QUERY_KEY,
-        queryFn: fetchConfig,
+        queryKey: GLOBAL_CONFIG_QUERY_KEY,
+        queryFn: () => fetchConfig('/api/config?scope=global'),
     });
 
     return {
-        config: data ?? DEFAULT_CONFIG,
+        config: data?.config ?? DEFAULT_CONFIG,
         loading: isLoading,
         error: error instanceof Error ? error.message : error ? String(error) : null,
     };
@@ -365,7 +411,8 @@ export const useUpdateConfig = () => {
     const queryClient = useQueryClient();
 
     const mutation = useMutation({
-        mutationFn: async (newConfig: AppConfig): Promise<void> => {
+        mutationFn: async (newConfig: AppConfig): Promise<string | null> => {
+            const etag = queryClient.getQueryData<ConfigQueryData>(GLOBAL_CONFIG_QUERY_KEY)?.etag;
             const response = await fetch(
                 '/api/config?jellyfin_url=' + encodeURIComponent(getServerUrl() || ''),
                 {
@@ -373,23 +420,31 @@ export const useUpdateConfig = () => {
                     headers: {
                         'Content-Type': 'application/json',
                         Authorization: getAccessToken() || '',
+                        ...(etag ? { 'If-Match': etag } : {}),
                     },
                     body: JSON.stringify(newConfig),
                 }
             );
+            if (response.status === 412) {
+                throw new ConfigConflictError();
+            }
             if (!response.ok) {
                 throw new Error(`Failed to update config: ${response.statusText}`);
             }
+            return response.headers.get('ETag');
         },
-        onSuccess: (_data, newConfig) => {
-            queryClient.setQueryData(CONFIG_QUERY_KEY, {
-                ...DEFAULT_CONFIG,
-                ...newConfig,
-                itemPage: {
-                    ...DEFAULT_ITEM_PAGE_SETTINGS,
-                    ...newConfig.itemPage,
-                },
+        onSuccess: (etag, newConfig) => {
+            queryClient.setQueryData<ConfigQueryData>(GLOBAL_CONFIG_QUERY_KEY, {
+                config: withDefaults(newConfig),
+                etag,
             });
+            // The merged config of this user may differ from the global one
+            queryClient.invalidateQueries({ queryKey: [...CONFIG_QUERY_KEY, 'caller'] });
+        },
+        onError: (error) => {
+            if (error instanceof ConfigConflictError) {
+                queryClient.invalidateQueries({ queryKey: GLOBAL_CONFIG_QUERY_KEY });
+            }
         },
     });
 
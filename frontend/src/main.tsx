@@ -20,9 +20,11 @@ import { MusicPlaybackProvider } from './context/MusicPlaybackProvider.tsx';
 import SettingsPage from './pages/Settings/SettingsPage.tsx';
 import SearchPage from './pages/Search/SearchPage.tsx';
 import PelagicaThemeLoader from './components/PelagicaThemeProvider.tsx';
+import BrandingIconsLoader from './components/BrandingIconsLoader.tsx';
 import ThemeBrowserPage from './pages/ThemeBroser/ThemeBrowserPage.tsx';
 import { Toaster } from './components/ui/sonner.tsx';
 import StatsConsentModal from './components/StatsConsentModal.tsx';
+import ServerEventsListener from './components/ServerEventsListener.tsx';
 
 const queryClient = new QueryClient();
 
@@ -35,8 +37,10 @@ createRoot(document.getElementById('root')!).render(
                         <KeyboardShortcuts />
                         <SearchCommand />
                         <PelagicaThemeLoader />
+                        <BrandingIconsLoader />
                         <Toaster />
                         <StatsConsentModal />
+                        <ServerEventsListener />
                         <Routes>
                             <Route path="/" element={<HomePage />} />
                             <Route path="/library" element={<LibraryPage />} />
@@ -36,6 +36,10 @@ const Disclaimer = ({ text }: { text: string | null | undefined }) => {
     );
 };
 
+/** Requests a downscaled WebP/PNG of a branding image from the backend */
+const getImageVariantUrl = (url: string, width: number) =>
+    `${url}${url.includes('?') ? '&' : '?'}width=${width}&format=auto`;
+
 const LoginPage = () => {
     const { config } = useConfig();
     const { data: branding } = useServerBranding();
@@ -228,7 +232,18 @@ const LoginPage = () => {
             className="flex items-center justify-center h-full w-full"
             sidebar={false}
             bgItem={
-                splashScreenUrl && branding?.SplashscreenEnabled ? (
+                config?.loginBackgroundUrl ? (
+                    <div className="fixed top-0 left-0 w-full h-full -z-20 overflow-hidden">
+                        <div className="absolute inset-0">
+                            <img
+                                src={getImageVariantUrl(config.loginBackgroundUrl, 2048)}
+                                alt=""
+                                className="w-full h-full object-cover opacity-60"
+                            />
+                        </div>
+                        <div className="absolute inset-0 bg-linear-to-b from-background/60 via-background/30 to-background" />
+                    </div>
+                ) : splashScreenUrl && branding?.SplashscreenEnabled ? (
                     <div className="fixed top-0 left-0 w-full h-full -z-20 overflow-hidden">
                         <div className="absolute inset-0">
                             <img
@@ -6,7 +6,8 @@ import {
     DETAIL_BADGES,
     EPISODE_DISPLAYS,
     DETAIL_FIELDS,
-    useConfig,
+    ConfigConflictError,
+    useGlobalConfig,
     useUpdateConfig,
     type DetailBadge,
     type DetailField,
@@ -630,7 +631,7 @@ const StatsConsentSetting = () => {
 
 const SettingsPage = () => {
     const { t } = useTranslation('settings');
-    const { config, loading, error } = useConfig();
+    const { config, loading, error } = useGlobalConfig();
     const { updateConfig, loading: updating } = useUpdateConfig();
     const [serverAddress, setServerAddress] = useState('');
     const [streamystatsUrl, setStreamystatsUrl] = useState('');
@@ -653,6 +654,8 @@ const SettingsPage = () => {
     const [logoDarkUrl, setLogoDarkUrl] = useState<string>('');
     const [logoLightFile, setLogoLightFile] = useState<File | null>(null);
     const [logoDarkFile, setLogoDarkFile] = useState<File | null>(null);
+    const [iconFile, setIconFile] = useState<File | null>(null);
+    const [loginBackgroundFile, setLoginBackgroundFile] = useState<File | null>(null);
     const { data: themes, isLoading: themesLoading } = useThemes();
     const { mutate: deleteTheme, isPending: isDeletingTheme } = useDeleteTheme();
     const [showThemeUploadDialog, setShowThemeUploadDialog] = useState(false);
@@ -740,7 +743,7 @@ const SettingsPage = () => {
         }
 
         const payload = (await response.json()) as { url?: string };
-        const uploadedUrl = payload.url ? `${payload.url}?v=${Date.now()}` : '';
+        const uploadedUrl = payload.url || '';
 
         if (mode === 'light') {
             setLogoLightUrl(uploadedUrl);
@@ -774,6 +777,48 @@ const SettingsPage = () => {
         }
     };
 
+    const handleBrandingAssetUpload = async (
+        asset: 'icon' | 'login-background',
+        field: string,
+        file: File
+    ) => {
+        const formData = new FormData();
+        formData.append(field, file);
+
+        const jellyfinUrl = getServerUrl() || '';
+        const response = await fetch(
+            `/api/branding/${asset}?jellyfin_url=${encodeURIComponent(jellyfinUrl)}`,
+            {
+                method: 'POST',
+                headers: {
+                    Authorization: getAccessToken() || '',
+                },
+                body: formData,
+            }
+        );
+
+        if (!response.ok) {
+            throw new Error(`Failed to upload ${asset}`);
+        }
+    };
+
+    const handleResetBrandingAsset = async (asset: 'icon' | 'login-background') => {
+        const jellyfinUrl = getServerUrl() || '';
+        const response = await fetch(
+            `/api/branding/${asset}?jellyfin_url=${encodeURIComponent(jellyfinUrl)}`,
+            {
+                method: 'DELETE',
+                headers: {
+                    Authorization: getAccessToken() || '',
+                },
+            }
+        );
+
+        if (!response.ok) {
+            throw new Error(`Failed to reset ${asset}`);
+        }
+    };
+
     const handleUpdateConfig = async () => {
         // update config takes in the whole config object, so we need to merge the existing config with the updated values
         if (config) {
@@ -811,7 +856,13 @@ const SettingsPage = () => {
                 toast.success(t('settings_saved'));
             } catch (e) {
                 console.error('Error updating config:', e);
-                toast.error(t('settings_save_error'));
+                toast.error(
+                    t(
+                        e instanceof ConfigConflictError
+                            ? 'settings_save_conflict'
+                            : 'settings_save_error'
+                    )
+                );
             }
         }
     };
@@ -1002,6 +1053,101 @@ const SettingsPage = () => {
                             {t('reset_dark_logo_label')}
                         </Button>
                     </div>
+                    <div className="mt-3">
+                        <Label className="mb-2 block">{t('upload_icon_label')}</Label>
+                        <p className="mb-2 text-sm text-muted-foreground">
+                            {t('icon_description')}
+                        </p>
+                        <FileDropInput
+                            accept="image/png,image/jpeg,image/webp,image/gif"
+                            value={iconFile}
+                            onChange={(file) => {
+                                setIconFile(file);
+                                if (!file) return;
+
+                                void (async () => {
+                                    try {
+                                        await handleBrandingAssetUpload('icon', 'icon', file);
+                                        toast.success(t('icon_upload_success'));
+                                    } catch (uploadError) {
+                                        console.error('Error uploading app icon:', uploadError);
+                                        toast.error(t('icon_upload_error'));
+                                    } finally {
+                                        setIconFile(null);
+                                    }
+                                })();
+                            }}
+                        />
+                        <Button
+                            type="button"
+                            variant="outline"
+                            size="sm"
+                            className="mt-2"
+                            onClick={async () => {
+                                try {
+                                    await handleResetBrandingAsset('icon');
+                                    toast.success(t('icon_reset_success'));
+                                } catch (resetError) {
+                                    console.error('Error resetting app icon:', resetError);
+                                    toast.error(t('icon_reset_error'));
+                                }
+                            }}
+                        >
+                            <RotateCcw />
+                            {t('reset_icon_label')}
+                        </Button>
+                    </div>
+                    <div className="mt-3">
+                        <Label className="mb-2 block">{t('upload_login_background_label')}</Label>
+                        <p className="mb-2 text-sm text-muted-foreground">
+                            {t('login_background_description')}
+                        </p>
+                        <FileDropInput
+                            accept="image/*"
+                            value={loginBackgroundFile}
+                            onChange={(file) => {
+                                setLoginBackgroundFile(file);
+                                if (!file) return;
+
+                                void (async () => {
+                                    try {
+                                        await handleBrandingAssetUpload(
+                                            'login-background',
+                                            'background',
+                                            file
+                                        );
+                                        toast.success(t('login_background_upload_success'));
+                                    } catch (uploadError) {
+                                        console.error(
+                                            'Error uploading login background:',
+                                            uploadError
+                                        );
+                                        toast.error(t('login_background_upload_error'));
+                                    } finally {
+                                        setLoginBackgroundFile(null);
+                                    }
+                                })();
+                            }}
+                        />
+                        <Button
+                            type="button"
+                            variant="outline"
+                            size="sm"
+                            className="mt-2"
+                            onClick={async () => {
+                                try {
+                                    await handleResetBrandingAsset('login-background');
+                                    toast.success(t('login_background_reset_success'));
+                                } catch (resetError) {
+                                    console.error('Error resetting login background:', resetError);
+                                    toast.error(t('login_background_reset_error'));
+                                }
+                            }}
+                        >
+                            <RotateCcw />
+                            {t('reset_login_background_label')}
+                        </Button>
+                    </div>
                 </TabsContent>
                 <TabsContent value="general" className="max-w-200">
                     <h1 className="mb-2 mt-2 text-2xl font-bold leading-none tracking-tight">
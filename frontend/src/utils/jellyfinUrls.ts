@@ -270,8 +270,20 @@ export function getPrimaryImageUrl(
     }
 }
 
-export function getStudioImageUrl(studioName: string) {
-    return `/api/studios/${encodeURIComponent(studioName)}/thumb`;
+export function getStudioImageUrl(studioName: string, size?: { width?: number; height?: number }) {
+    const params = new URLSearchParams();
+    if (size?.width) {
+        params.append('width', size.width.toString());
+    }
+    if (size?.height) {
+        params.append('height', size.height.toString());
+    }
+    if (params.size > 0) {
+        params.append('format', 'auto');
+    }
+
+    const query = params.size > 0 ? `?${params.toString()}` : '';
+    return `/api/studios/${encodeURIComponent(studioName)}/thumb${query}`;
 }
 
 export function getTrickplayImageUrl(itemId: string, width: number, imageIndex: number) {